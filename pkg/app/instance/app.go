@@ -4,6 +4,8 @@ import (
 	writer "github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/database/writer"
 	subscriber "github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/subscriber/service"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,6 +16,7 @@ type AppInstance struct {
 	done       chan os.Signal
 	writer     *writer.Writer
 	subscriber *subscriber.Subscriber
+	httpServer *http.Server
 }
 
 func NewAppInstance() *AppInstance {
@@ -35,8 +38,14 @@ func (a *AppInstance) Init() error {
 	a.writer = writer.NewWriter()
 	a.subscriber = subscriber.NewSubscriber(a)
 
+	// Initializing HTTP server for metrics and health checks
+	err := a.initHTTPServer()
+	if err != nil {
+		return err
+	}
+
 	// Initializing Writer
-	err := a.initWriter()
+	err = a.initWriter()
 	if err != nil {
 		return err
 	}
@@ -60,9 +69,30 @@ func (a *AppInstance) Run() error {
 	}
 
 	<-a.done
-	a.subscriber.Stop()
-	time.Sleep(5 * time.Second)
+
+	a.shutdown()
+
 	log.Error("Bye!")
 
 	return nil
 }
+
+func (a *AppInstance) shutdown() {
+
+	log.Info("Shutting down...")
+
+	// Stop receiving data from gravity
+	a.subscriber.Stop()
+
+	// Waiting for buffered commands to be written and acknowledged
+	viper.SetDefault("writer.shutdownTimeout", 30)
+	timeout := viper.GetDuration("writer.shutdownTimeout") * time.Second
+	err := a.writer.Drain(timeout)
+	if err != nil {
+		log.Error(err)
+	}
+
+	a.subscriber.Close()
+	a.writer.Close()
+	a.closeHTTPServer()
+}
@@ -0,0 +1,95 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type HealthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (a *AppInstance) initHealthHandlers(mux *http.ServeMux) {
+
+	viper.SetDefault("health.writerStuckThreshold", 60)
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]string{
+			"writer": a.checkWriter(),
+		}
+
+		a.writeHealthReport(w, checks)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]string{
+			"mongodb": a.checkMongoDB(r.Context()),
+			"gravity": a.checkGravity(),
+			"writer":  a.checkWriter(),
+		}
+
+		a.writeHealthReport(w, checks)
+	})
+}
+
+func (a *AppInstance) writeHealthReport(w http.ResponseWriter, checks map[string]string) {
+
+	report := HealthReport{
+		Status: "ok",
+		Checks: checks,
+	}
+
+	statusCode := http.StatusOK
+	for _, result := range checks {
+		if result != "ok" {
+			report.Status = "fail"
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(&report)
+}
+
+func (a *AppInstance) checkMongoDB(ctx context.Context) string {
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := a.writer.Ping(ctx)
+	if err != nil {
+		return err.Error()
+	}
+
+	return "ok"
+}
+
+func (a *AppInstance) checkGravity() string {
+
+	if !a.subscriber.IsConnected() {
+		return "disconnected"
+	}
+
+	return "ok"
+}
+
+func (a *AppInstance) checkWriter() string {
+
+	threshold := viper.GetDuration("health.writerStuckThreshold") * time.Second
+
+	// Writer keeps retrying for too long
+	duration := a.writer.GetRetryingDuration()
+	if duration > threshold {
+		return fmt.Sprintf("retrying for %s", duration.Round(time.Second))
+	}
+
+	return "ok"
+}
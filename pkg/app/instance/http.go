@@ -0,0 +1,66 @@
+package instance
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func (a *AppInstance) initHTTPServer() error {
+
+	viper.SetDefault("http.enabled", true)
+	viper.SetDefault("http.host", "0.0.0.0:8080")
+
+	if !viper.GetBool("http.enabled") {
+		return nil
+	}
+
+	host := viper.GetString("http.host")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	a.initHealthHandlers(mux)
+
+	// Listen before running so that errors can be reported at startup
+	listener, err := net.Listen("tcp", host)
+	if err != nil {
+		return err
+	}
+
+	a.httpServer = &http.Server{
+		Handler: mux,
+	}
+
+	log.WithFields(log.Fields{
+		"host": host,
+	}).Info("Starting HTTP server")
+
+	go func() {
+		err := a.httpServer.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Error(err)
+		}
+	}()
+
+	return nil
+}
+
+func (a *AppInstance) closeHTTPServer() {
+
+	if a.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := a.httpServer.Shutdown(ctx)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,244 @@
+package converter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Layouts of time string accepted by date converter
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func ToString(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_STRING, gravity_sdk_types_record.DataType_BINARY:
+		return string(value.Value), nil
+	case gravity_sdk_types_record.DataType_BOOLEAN:
+		v, _ := ToBool(value)
+		return strconv.FormatBool(v.(bool)), nil
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		return strconv.FormatFloat(gravity_sdk_types_record.GetValue(value).(float64), 'f', -1, 64), nil
+	case gravity_sdk_types_record.DataType_TIME:
+		return gravity_sdk_types_record.GetValue(value).(time.Time).Format(time.RFC3339Nano), nil
+	case gravity_sdk_types_record.DataType_INT64, gravity_sdk_types_record.DataType_UINT64:
+		return fmt.Sprintf("%d", gravity_sdk_types_record.GetValue(value)), nil
+	}
+
+	return nil, unsupported(value, "string")
+}
+
+func ToBool(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_BOOLEAN:
+		return gravity_sdk_types_record.GetValue(value).(int8) == 1, nil
+	case gravity_sdk_types_record.DataType_STRING:
+		return strconv.ParseBool(string(value.Value))
+	case gravity_sdk_types_record.DataType_INT64:
+		return gravity_sdk_types_record.GetValue(value).(int64) != 0, nil
+	case gravity_sdk_types_record.DataType_UINT64:
+		return gravity_sdk_types_record.GetValue(value).(uint64) != 0, nil
+	}
+
+	return nil, unsupported(value, "bool")
+}
+
+func ToInt64(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_INT64:
+		return gravity_sdk_types_record.GetValue(value), nil
+	case gravity_sdk_types_record.DataType_UINT64:
+		v := gravity_sdk_types_record.GetValue(value).(uint64)
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", v)
+		}
+
+		return int64(v), nil
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		v := gravity_sdk_types_record.GetValue(value).(float64)
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return nil, fmt.Errorf("%v is not an int64", v)
+		}
+
+		return int64(v), nil
+	case gravity_sdk_types_record.DataType_BOOLEAN:
+		return int64(gravity_sdk_types_record.GetValue(value).(int8)), nil
+	case gravity_sdk_types_record.DataType_STRING:
+		return strconv.ParseInt(strings.TrimSpace(string(value.Value)), 10, 64)
+	case gravity_sdk_types_record.DataType_TIME:
+		return gravity_sdk_types_record.GetValue(value).(time.Time).UnixNano() / int64(time.Millisecond), nil
+	}
+
+	return nil, unsupported(value, "int64")
+}
+
+func ToDouble(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		return gravity_sdk_types_record.GetValue(value), nil
+	case gravity_sdk_types_record.DataType_INT64:
+		return float64(gravity_sdk_types_record.GetValue(value).(int64)), nil
+	case gravity_sdk_types_record.DataType_UINT64:
+		return float64(gravity_sdk_types_record.GetValue(value).(uint64)), nil
+	case gravity_sdk_types_record.DataType_STRING:
+		return strconv.ParseFloat(strings.TrimSpace(string(value.Value)), 64)
+	}
+
+	return nil, unsupported(value, "double")
+}
+
+func ToDecimal128(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	var str string
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		// Shortest representation fits in 34 digits of decimal128
+		str = strconv.FormatFloat(gravity_sdk_types_record.GetValue(value).(float64), 'g', -1, 64)
+	case gravity_sdk_types_record.DataType_INT64, gravity_sdk_types_record.DataType_UINT64:
+		v, _ := ToString(value)
+		str = v.(string)
+	case gravity_sdk_types_record.DataType_STRING:
+		str = strings.TrimSpace(string(value.Value))
+	default:
+		return nil, unsupported(value, "decimal")
+	}
+
+	return primitive.ParseDecimal128(str)
+}
+
+// ToDateTime converts time, unix timestamp in milliseconds or time string to BSON date
+func ToDateTime(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_TIME:
+		return primitive.NewDateTimeFromTime(gravity_sdk_types_record.GetValue(value).(time.Time)), nil
+	case gravity_sdk_types_record.DataType_INT64:
+		return primitive.DateTime(gravity_sdk_types_record.GetValue(value).(int64)), nil
+	case gravity_sdk_types_record.DataType_UINT64:
+		return primitive.DateTime(int64(gravity_sdk_types_record.GetValue(value).(uint64))), nil
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		return primitive.DateTime(int64(gravity_sdk_types_record.GetValue(value).(float64))), nil
+	case gravity_sdk_types_record.DataType_STRING:
+		str := strings.TrimSpace(string(value.Value))
+		for _, layout := range TimeLayouts {
+			t, err := time.Parse(layout, str)
+			if err == nil {
+				return primitive.NewDateTimeFromTime(t), nil
+			}
+		}
+
+		return nil, fmt.Errorf("Invalid time: %s", str)
+	}
+
+	return nil, unsupported(value, "date")
+}
+
+func ToObjectID(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_STRING:
+		return primitive.ObjectIDFromHex(strings.TrimSpace(string(value.Value)))
+	case gravity_sdk_types_record.DataType_BINARY:
+		var id primitive.ObjectID
+		if len(value.Value) != len(id) {
+			return nil, fmt.Errorf("Invalid ObjectID length: %d", len(value.Value))
+		}
+
+		copy(id[:], value.Value)
+
+		return id, nil
+	}
+
+	return nil, unsupported(value, "objectId")
+}
+
+// ToUUID converts UUID string or 16 bytes binary to BSON binary with UUID subtype
+func ToUUID(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	var data []byte
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_STRING:
+		str := strings.Trim(strings.TrimSpace(string(value.Value)), "{}")
+		b, err := hex.DecodeString(strings.ReplaceAll(str, "-", ""))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid UUID: %s", str)
+		}
+
+		data = b
+	case gravity_sdk_types_record.DataType_BINARY:
+		data = value.Value
+	default:
+		return nil, unsupported(value, "uuid")
+	}
+
+	if len(data) != 16 {
+		return nil, fmt.Errorf("Invalid UUID length: %d", len(data))
+	}
+
+	return primitive.Binary{
+		Subtype: 0x04,
+		Data:    data,
+	}, nil
+}
+
+func ToBinary(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if isNull(value) {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_BINARY, gravity_sdk_types_record.DataType_STRING:
+		return primitive.Binary{
+			Data: value.Value,
+		}, nil
+	}
+
+	return nil, unsupported(value, "binary")
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Converter turns value from gravity into the value written to MongoDB
+type Converter func(value *gravity_sdk_types_record.Value) (interface{}, error)
+
+var converters sync.Map
+
+func init() {
+	Register("auto", Convert)
+	Register("string", ToString)
+	Register("bool", ToBool)
+	Register("int64", ToInt64)
+	Register("double", ToDouble)
+	Register("decimal", ToDecimal128)
+	Register("date", ToDateTime)
+	Register("objectId", ToObjectID)
+	Register("uuid", ToUUID)
+	Register("binary", ToBinary)
+}
+
+// Register adds converter which can be referred by name in rules
+func Register(name string, fn Converter) {
+	converters.Store(name, fn)
+}
+
+func Get(name string) (Converter, bool) {
+
+	v, ok := converters.Load(name)
+	if !ok {
+		return nil, false
+	}
+
+	return v.(Converter), true
+}
+
+// Convert returns native BSON value according to type of gravity value
+func Convert(value *gravity_sdk_types_record.Value) (interface{}, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_BOOLEAN:
+		return ToBool(value)
+	case gravity_sdk_types_record.DataType_UINT64:
+
+		// Unsigned integer which is out of range of int64 is kept as decimal
+		v := gravity_sdk_types_record.GetValue(value).(uint64)
+		if v > math.MaxInt64 {
+			return ToDecimal128(value)
+		}
+
+		return int64(v), nil
+	case gravity_sdk_types_record.DataType_TIME:
+		return ToDateTime(value)
+	case gravity_sdk_types_record.DataType_BINARY:
+		return ToBinary(value)
+	case gravity_sdk_types_record.DataType_MAP:
+
+		if value.Map == nil {
+			return bson.M{}, nil
+		}
+
+		doc := make(bson.M, len(value.Map.Fields))
+		for _, field := range value.Map.Fields {
+			v, err := Convert(field.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", field.Name, err)
+			}
+
+			doc[field.Name] = v
+		}
+
+		return doc, nil
+	case gravity_sdk_types_record.DataType_ARRAY:
+
+		if value.Array == nil {
+			return bson.A{}, nil
+		}
+
+		arr := make(bson.A, 0, len(value.Array.Elements))
+		for _, element := range value.Array.Elements {
+			v, err := Convert(element)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, v)
+		}
+
+		return arr, nil
+	}
+
+	return gravity_sdk_types_record.GetValue(value), nil
+}
+
+func isNull(value *gravity_sdk_types_record.Value) bool {
+	return value == nil || value.Type == gravity_sdk_types_record.DataType_NULL
+}
+
+func unsupported(value *gravity_sdk_types_record.Value, target string) error {
+	return fmt.Errorf("Cannot convert %s to %s", value.Type.String(), target)
+}
@@ -0,0 +1,176 @@
+package converter
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newValue(data interface{}) *gravity_sdk_types_record.Value {
+
+	switch v := data.(type) {
+	case bool:
+		b := byte(0)
+		if v {
+			b = 1
+		}
+
+		return &gravity_sdk_types_record.Value{
+			Type:  gravity_sdk_types_record.DataType_BOOLEAN,
+			Value: []byte{b},
+		}
+	case []byte:
+		return &gravity_sdk_types_record.Value{
+			Type:  gravity_sdk_types_record.DataType_BINARY,
+			Value: v,
+		}
+	}
+
+	value, err := gravity_sdk_types_record.GetValueFromInterface(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return value
+}
+
+func mustDecimal(str string) primitive.Decimal128 {
+
+	d, err := primitive.ParseDecimal128(str)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+type converterTest struct {
+	name     string
+	value    *gravity_sdk_types_record.Value
+	expected interface{}
+	fails    bool
+}
+
+func runConverterTests(t *testing.T, fn Converter, tests []converterTest) {
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			v, err := fn(test.value)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %v", v)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Fatalf("expected %#v, got %#v", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	runConverterTests(t, Convert, []converterTest{
+		{name: "nil", value: nil, expected: nil},
+		{name: "true", value: newValue(true), expected: true},
+		{name: "false", value: newValue(false), expected: false},
+		{name: "int64", value: newValue(int64(-3)), expected: int64(-3)},
+		{name: "uint64", value: newValue(uint64(3)), expected: int64(3)},
+		{name: "uint64 out of int64", value: newValue(uint64(math.MaxUint64)), expected: mustDecimal("18446744073709551615")},
+		{name: "float64", value: newValue(3.5), expected: 3.5},
+		{name: "string", value: newValue("fred"), expected: "fred"},
+		{name: "time", value: newValue(now), expected: primitive.NewDateTimeFromTime(now)},
+		{name: "binary", value: newValue([]byte{1, 2}), expected: primitive.Binary{Data: []byte{1, 2}}},
+		{
+			name: "map",
+			value: &gravity_sdk_types_record.Value{
+				Type: gravity_sdk_types_record.DataType_MAP,
+				Map: &gravity_sdk_types_record.MapValue{
+					Fields: []*gravity_sdk_types_record.Field{
+						{Name: "name", Value: newValue("fred")},
+						{Name: "active", Value: newValue(true)},
+					},
+				},
+			},
+			expected: bson.M{"name": "fred", "active": true},
+		},
+		{
+			name: "array",
+			value: &gravity_sdk_types_record.Value{
+				Type: gravity_sdk_types_record.DataType_ARRAY,
+				Array: &gravity_sdk_types_record.ArrayValue{
+					Elements: []*gravity_sdk_types_record.Value{newValue(int64(1)), newValue(false)},
+				},
+			},
+			expected: bson.A{int64(1), false},
+		},
+		{name: "empty map", value: &gravity_sdk_types_record.Value{Type: gravity_sdk_types_record.DataType_MAP}, expected: bson.M{}},
+		{name: "empty array", value: &gravity_sdk_types_record.Value{Type: gravity_sdk_types_record.DataType_ARRAY}, expected: bson.A{}},
+	})
+}
+
+func TestToDecimal128(t *testing.T) {
+
+	runConverterTests(t, ToDecimal128, []converterTest{
+		{name: "null", value: newValue(nil), expected: nil},
+		{name: "int64", value: newValue(int64(-42)), expected: mustDecimal("-42")},
+		{name: "uint64", value: newValue(uint64(math.MaxUint64)), expected: mustDecimal("18446744073709551615")},
+		{name: "float64", value: newValue(3.25), expected: mustDecimal("3.25")},
+		{name: "small float64", value: newValue(1e-300), expected: mustDecimal("1e-300")},
+		{name: "large float64", value: newValue(math.MaxFloat64), expected: mustDecimal("1.7976931348623157e+308")},
+		{name: "string", value: newValue(" 12345678901234567890.123 "), expected: mustDecimal("12345678901234567890.123")},
+		{name: "invalid string", value: newValue("fred"), fails: true},
+		{name: "bool", value: newValue(true), fails: true},
+	})
+}
+
+func TestToDateTime(t *testing.T) {
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	runConverterTests(t, ToDateTime, []converterTest{
+		{name: "null", value: newValue(nil), expected: nil},
+		{name: "time", value: newValue(date), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "milliseconds", value: newValue(int64(1577934245000)), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "unsigned milliseconds", value: newValue(uint64(1577934245000)), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "float milliseconds", value: newValue(1577934245000.0), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "RFC3339", value: newValue("2020-01-02T03:04:05Z"), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "datetime", value: newValue("2020-01-02 03:04:05"), expected: primitive.NewDateTimeFromTime(date)},
+		{name: "date", value: newValue("2020-01-02"), expected: primitive.NewDateTimeFromTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))},
+		{name: "invalid string", value: newValue("yesterday"), fails: true},
+		{name: "bool", value: newValue(true), fails: true},
+	})
+}
+
+func TestToUUID(t *testing.T) {
+
+	data := []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	expected := primitive.Binary{Subtype: 0x04, Data: data}
+
+	runConverterTests(t, ToUUID, []converterTest{
+		{name: "null", value: newValue(nil), expected: nil},
+		{name: "string", value: newValue("123e4567-e89b-12d3-a456-426614174000"), expected: expected},
+		{name: "braces", value: newValue("{123e4567-e89b-12d3-a456-426614174000}"), expected: expected},
+		{name: "without dashes", value: newValue("123e4567e89b12d3a456426614174000"), expected: expected},
+		{name: "binary", value: newValue(data), expected: expected},
+		{name: "invalid string", value: newValue("123e4567-e89b-12d3-a456-42661417400z"), fails: true},
+		{name: "short string", value: newValue("123e4567"), fails: true},
+		{name: "short binary", value: newValue([]byte{1, 2, 3}), fails: true},
+		{name: "int64", value: newValue(int64(1)), fails: true},
+	})
+}
@@ -2,20 +2,23 @@ package database
 
 import (
 	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
 )
 
 type DBCommand interface {
 	GetReference() interface{}
 	GetPipelineID() uint64
 	GetSequence() uint64
-	GetTables() []string
 }
 
 type CompletionHandler func(DBCommand)
 
 type Writer interface {
 	Init() error
-	ProcessData(interface{}, *gravity_sdk_types_record.Record, []string) error
+	Begin(uint64, uint64)
+	ProcessData(interface{}, uint64, uint64, *gravity_sdk_types_record.Record, *rule.Target) (bool, error)
+	Seal(uint64, uint64)
 	SetCompletionHandler(CompletionHandler)
-	Truncate(string) error
+	Truncate(*rule.Target) error
+	PrepareTargets([]*rule.Target) error
 }
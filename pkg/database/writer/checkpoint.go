@@ -0,0 +1,234 @@
+package writer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/watermark"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Checkpoint struct {
+	PipelineID uint64    `bson:"_id"`
+	Sequence   uint64    `bson:"sequence"`
+	UpdatedAt  time.Time `bson:"updatedAt"`
+}
+
+type pipelineState struct {
+	*watermark.Watermark
+	saved uint64
+}
+
+// CheckpointTracker records how far each pipeline has been applied
+type CheckpointTracker struct {
+	connector     *MongoDBConnector
+	enabled       bool
+	collection    string
+	interval      time.Duration
+	stamp         bool
+	pipelineField string
+	sequenceField string
+	pipelines     map[uint64]*pipelineState
+	mutex         sync.Mutex
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+func NewCheckpointTracker(connector *MongoDBConnector) *CheckpointTracker {
+
+	viper.SetDefault("checkpoint.enabled", false)
+	viper.SetDefault("checkpoint.collection", "_gravity_checkpoints")
+	viper.SetDefault("checkpoint.interval", 5)
+	viper.SetDefault("checkpoint.stamp", false)
+	viper.SetDefault("checkpoint.pipelineField", "_pipelineID")
+	viper.SetDefault("checkpoint.sequenceField", "_sequence")
+
+	return &CheckpointTracker{
+		connector:     connector,
+		enabled:       viper.GetBool("checkpoint.enabled"),
+		collection:    viper.GetString("checkpoint.collection"),
+		interval:      viper.GetDuration("checkpoint.interval") * time.Second,
+		stamp:         viper.GetBool("checkpoint.stamp"),
+		pipelineField: viper.GetString("checkpoint.pipelineField"),
+		sequenceField: viper.GetString("checkpoint.sequenceField"),
+		pipelines:     make(map[uint64]*pipelineState),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (tracker *CheckpointTracker) Init() error {
+
+	if !tracker.enabled {
+		close(tracker.done)
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"collection": tracker.collection,
+	}).Info("Initializing checkpoint tracker")
+
+	go tracker.run()
+
+	return nil
+}
+
+func (tracker *CheckpointTracker) run() {
+
+	defer close(tracker.done)
+
+	ticker := time.NewTicker(tracker.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tracker.stop:
+			tracker.save()
+			return
+		case <-ticker.C:
+			tracker.save()
+		}
+	}
+}
+
+// Close saves checkpoints for the last time
+func (tracker *CheckpointTracker) Close() {
+
+	if tracker.enabled {
+		close(tracker.stop)
+	}
+
+	<-tracker.done
+}
+
+// Stamp puts pipeline ID and sequence of command to document
+func (tracker *CheckpointTracker) Stamp(cmd *DBCommand, values map[string]interface{}) {
+
+	if !tracker.stamp || cmd.Sequence == 0 {
+		return
+	}
+
+	values[tracker.pipelineField] = int64(cmd.PipelineID)
+	values[tracker.sequenceField] = int64(cmd.Sequence)
+}
+
+// Begin holds sequence of event before its commands are pushed, so checkpoint
+// never passes the event until Seal is called.
+func (tracker *CheckpointTracker) Begin(pipelineID uint64, sequence uint64) {
+	tracker.hold(pipelineID, sequence)
+}
+
+// Seal releases sequence held by Begin after all commands of event were pushed
+func (tracker *CheckpointTracker) Seal(pipelineID uint64, sequence uint64) {
+	tracker.release(pipelineID, sequence)
+}
+
+// Add registers command which is going to be written
+func (tracker *CheckpointTracker) Add(cmd *DBCommand) {
+	tracker.hold(cmd.PipelineID, cmd.Sequence)
+}
+
+// Done marks command as applied
+func (tracker *CheckpointTracker) Done(cmd *DBCommand) {
+	tracker.release(cmd.PipelineID, cmd.Sequence)
+}
+
+func (tracker *CheckpointTracker) hold(pipelineID uint64, sequence uint64) {
+
+	// Snapshot records are not in sequence
+	if !tracker.enabled || sequence == 0 {
+		return
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	state, ok := tracker.pipelines[pipelineID]
+	if !ok {
+		state = &pipelineState{
+			Watermark: watermark.NewWatermark(),
+		}
+		tracker.pipelines[pipelineID] = state
+	}
+
+	state.Hold(sequence)
+}
+
+func (tracker *CheckpointTracker) release(pipelineID uint64, sequence uint64) {
+
+	if !tracker.enabled || sequence == 0 {
+		return
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	state, ok := tracker.pipelines[pipelineID]
+	if !ok {
+		return
+	}
+
+	state.Release(sequence)
+}
+
+func (tracker *CheckpointTracker) getCheckpoints() []*Checkpoint {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	checkpoints := make([]*Checkpoint, 0, len(tracker.pipelines))
+	for pipelineID, state := range tracker.pipelines {
+
+		seq := state.Get()
+		if seq <= state.saved {
+			continue
+		}
+
+		checkpoints = append(checkpoints, &Checkpoint{
+			PipelineID: pipelineID,
+			Sequence:   seq,
+		})
+	}
+
+	return checkpoints
+}
+
+func (tracker *CheckpointTracker) save() {
+
+	checkpoints := tracker.getCheckpoints()
+	if len(checkpoints) == 0 {
+		return
+	}
+
+	db, release := tracker.connector.AcquireDatabase("")
+	defer release()
+
+	collection := db.Collection(tracker.collection)
+	opts := options.Update().SetUpsert(true)
+
+	for _, checkpoint := range checkpoints {
+
+		_, err := collection.UpdateOne(context.Background(),
+			bson.M{"_id": checkpoint.PipelineID},
+			bson.M{
+				"$max": bson.M{"sequence": checkpoint.Sequence},
+				"$set": bson.M{"updatedAt": time.Now()},
+			},
+			opts,
+		)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"pipeline": checkpoint.PipelineID,
+			}).Error(err)
+			continue
+		}
+
+		tracker.mutex.Lock()
+		tracker.pipelines[checkpoint.PipelineID].saved = checkpoint.Sequence
+		tracker.mutex.Unlock()
+	}
+}
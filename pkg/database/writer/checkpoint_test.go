@@ -0,0 +1,84 @@
+package writer
+
+import (
+	"testing"
+)
+
+func TestCheckpointWatermark(t *testing.T) {
+
+	type step struct {
+		op       string
+		sequence uint64
+	}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		expected uint64
+	}{
+		{"nothing", nil, 0},
+		{"all done", []step{{"add", 1}, {"add", 2}, {"done", 1}, {"done", 2}}, 2},
+		{"done out of order", []step{{"add", 1}, {"add", 2}, {"add", 3}, {"done", 3}, {"done", 1}}, 1},
+		{"oldest pending", []step{{"add", 1}, {"add", 2}, {"done", 2}}, 0},
+		{"commands of the same event", []step{{"add", 5}, {"add", 5}, {"done", 5}}, 4},
+		{
+			name: "event held while pushing commands",
+			steps: []step{
+				{"begin", 5},
+				{"add", 5}, {"done", 5},
+				{"begin", 6}, {"add", 6}, {"seal", 6}, {"done", 6},
+			},
+			expected: 4,
+		},
+		{
+			name: "event sealed",
+			steps: []step{
+				{"begin", 5}, {"add", 5}, {"done", 5}, {"add", 5}, {"seal", 5},
+				{"begin", 6}, {"add", 6}, {"seal", 6}, {"done", 6},
+			},
+			expected: 4,
+		},
+		{
+			name: "all events sealed and done",
+			steps: []step{
+				{"begin", 5}, {"add", 5}, {"add", 5}, {"seal", 5}, {"done", 5}, {"done", 5},
+				{"begin", 6}, {"seal", 6},
+			},
+			expected: 6,
+		},
+		{"snapshot records", []step{{"begin", 0}, {"add", 0}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			tracker := &CheckpointTracker{
+				enabled:   true,
+				pipelines: make(map[uint64]*pipelineState),
+			}
+
+			for _, s := range test.steps {
+				cmd := &DBCommand{PipelineID: 1, Sequence: s.sequence}
+				switch s.op {
+				case "begin":
+					tracker.Begin(1, s.sequence)
+				case "seal":
+					tracker.Seal(1, s.sequence)
+				case "add":
+					tracker.Add(cmd)
+				case "done":
+					tracker.Done(cmd)
+				}
+			}
+
+			var watermark uint64
+			for _, checkpoint := range tracker.getCheckpoints() {
+				watermark = checkpoint.Sequence
+			}
+
+			if watermark != test.expected {
+				t.Fatalf("expected watermark %d, got %d", test.expected, watermark)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package writer
+
+import (
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+// coalesceCommands merges commands on the same document into one command,
+// original commands are kept in merged command so all of them are completed.
+// Merged command takes the place of the last command, so that commands which
+// are not merged but change the same document (embedded records) are still
+// applied before it.
+func coalesceCommands(dbCommands []*DBCommand) []*DBCommand {
+
+	ids := make([]string, len(dbCommands))
+	docs := make(map[string]*DBCommand)
+	last := make(map[string]int)
+
+	for i, cmd := range dbCommands {
+
+		keys := getPrimaryKeys(cmd)
+		// Different records in the same array cannot be merged
+		if len(keys) == 0 || !hasPrimaryKey(cmd.Record, keys) || isEmbedded(cmd) {
+			continue
+		}
+
+		id := cmd.Record.Table + "\x00" + getKeyID(cmd.Record, keys)
+		ids[i] = id
+		last[id] = i
+
+		merged, ok := docs[id]
+		if !ok {
+			docs[id] = &DBCommand{
+				PipelineID: cmd.PipelineID,
+				Sequence:   cmd.Sequence,
+				Reference:  cmd.Reference,
+				Record:     cloneRecord(cmd.Record),
+				Target:     cmd.Target,
+				merged:     []*DBCommand{cmd},
+			}
+			continue
+		}
+
+		merged.merge(cmd)
+	}
+
+	results := make([]*DBCommand, 0, len(docs))
+	for i, cmd := range dbCommands {
+
+		id := ids[i]
+		if id == "" {
+			results = append(results, cmd)
+			continue
+		}
+
+		if last[id] != i {
+			continue
+		}
+
+		// Unwrap documents which have only one command
+		merged := docs[id]
+		if len(merged.merged) == 1 {
+			results = append(results, merged.merged[0])
+			continue
+		}
+
+		results = append(results, merged)
+	}
+
+	return results
+}
+
+func (cmd *DBCommand) merge(next *DBCommand) {
+
+	cmd.merged = append(cmd.merged, next)
+	cmd.PipelineID = next.PipelineID
+	cmd.Sequence = next.Sequence
+
+	record := cmd.Record
+
+	switch next.Record.Method {
+	case gravity_sdk_types_record.Method_DELETE:
+		// Nothing before matters
+		cmd.Record = cloneRecord(next.Record)
+		cmd.upsert = false
+
+	case gravity_sdk_types_record.Method_INSERT:
+		// Replace whatever document is there
+		cmd.Record = cloneRecord(next.Record)
+		cmd.upsert = true
+
+	case gravity_sdk_types_record.Method_UPDATE:
+		switch record.Method {
+		case gravity_sdk_types_record.Method_DELETE:
+			// Updating a deleted document changes nothing, but soft-deleted
+			// document is brought back by update
+			if getSoftDelete(cmd) != nil {
+				cmd.Record = cloneRecord(next.Record)
+			}
+		case gravity_sdk_types_record.Method_INSERT:
+			mergeFields(record, next.Record.Fields)
+			cmd.upsert = true
+		default:
+			mergeFields(record, next.Record.Fields)
+		}
+	}
+}
+
+func mergeFields(record *gravity_sdk_types_record.Record, fields []*gravity_sdk_types_record.Field) {
+
+	for _, field := range fields {
+
+		replaced := false
+		for i, f := range record.Fields {
+			if f.Name == field.Name {
+				record.Fields[i] = field
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			record.Fields = append(record.Fields, field)
+		}
+	}
+}
+
+func cloneRecord(record *gravity_sdk_types_record.Record) *gravity_sdk_types_record.Record {
+
+	fields := make([]*gravity_sdk_types_record.Field, len(record.Fields))
+	copy(fields, record.Fields)
+
+	return &gravity_sdk_types_record.Record{
+		EventName:  record.EventName,
+		Table:      record.Table,
+		Method:     record.Method,
+		PrimaryKey: record.PrimaryKey,
+		Fields:     fields,
+	}
+}
@@ -0,0 +1,206 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+)
+
+type coalesceResult struct {
+	method gravity_sdk_types_record.Method
+	upsert bool
+	merged int
+	fields map[string]interface{}
+}
+
+func getRecordValues(record *gravity_sdk_types_record.Record) map[string]interface{} {
+
+	values := make(map[string]interface{})
+	for _, field := range record.Fields {
+		values[field.Name] = gravity_sdk_types_record.GetValue(field.Value)
+	}
+
+	return values
+}
+
+func TestCoalesceCommands(t *testing.T) {
+
+	softDelete := &rule.Target{
+		SoftDelete: &rule.SoftDeleteRule{Field: "deleted"},
+	}
+
+	embedded := &rule.Target{
+		Embed: &rule.EmbedRule{Field: "items", ForeignKey: "orderID", ParentKey: "id"},
+	}
+
+	tests := []struct {
+		name     string
+		target   *rule.Target
+		targets  []*rule.Target
+		records  []*gravity_sdk_types_record.Record
+		expected []coalesceResult
+	}{
+		{
+			name: "different documents",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred"),
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(2), "name", "armani"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"id": int64(1), "name": "fred"}},
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"id": int64(2), "name": "armani"}},
+			},
+		},
+		{
+			name: "insert then update",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred", "age", int64(30)),
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "armani"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, true, 2, map[string]interface{}{"id": int64(1), "name": "armani", "age": int64(30)}},
+			},
+		},
+		{
+			name: "updates",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "age", int64(30)),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_UPDATE, false, 2, map[string]interface{}{"id": int64(1), "name": "fred", "age": int64(30)}},
+			},
+		},
+		{
+			name: "update then delete",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+				newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", int64(1)),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_DELETE, false, 2, map[string]interface{}{"id": int64(1)}},
+			},
+		},
+		{
+			name: "delete then insert",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", int64(1)),
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, true, 2, map[string]interface{}{"id": int64(1), "name": "fred"}},
+			},
+		},
+		{
+			name: "delete then update",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", int64(1)),
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_DELETE, false, 2, map[string]interface{}{"id": int64(1)}},
+			},
+		},
+		{
+			name:   "soft-delete then update",
+			target: softDelete,
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", int64(1)),
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_UPDATE, false, 2, map[string]interface{}{"id": int64(1), "name": "fred"}},
+			},
+		},
+		{
+			name: "without primary key",
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"),
+				newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"name": "fred"}},
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"name": "fred"}},
+			},
+		},
+		{
+			name:   "embedded records",
+			target: embedded,
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "orderID", int64(1)),
+				newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "orderID", int64(1)),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"id": int64(1), "orderID": int64(1)}},
+				{gravity_sdk_types_record.Method_UPDATE, false, 0, map[string]interface{}{"id": int64(1), "orderID": int64(1)}},
+			},
+		},
+		{
+			name:    "parent deleted after embedded record",
+			targets: []*rule.Target{nil, embedded, nil},
+			records: []*gravity_sdk_types_record.Record{
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred"),
+				newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(10), "orderID", int64(1)),
+				newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", int64(1)),
+			},
+			expected: []coalesceResult{
+				{gravity_sdk_types_record.Method_INSERT, false, 0, map[string]interface{}{"id": int64(10), "orderID": int64(1)}},
+				{gravity_sdk_types_record.Method_DELETE, false, 2, map[string]interface{}{"id": int64(1)}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			cmds := make([]*DBCommand, 0, len(test.records))
+			for i, record := range test.records {
+				target := test.target
+				if test.targets != nil {
+					target = test.targets[i]
+				}
+
+				cmds = append(cmds, &DBCommand{
+					PipelineID: 1,
+					Sequence:   uint64(i + 1),
+					Record:     record,
+					Target:     target,
+				})
+			}
+
+			original := getRecordValues(test.records[0])
+
+			results := coalesceCommands(cmds)
+			if len(results) != len(test.expected) {
+				t.Fatalf("expected %d commands, got %d", len(test.expected), len(results))
+			}
+
+			for i, expected := range test.expected {
+				cmd := results[i]
+
+				if cmd.Record.Method != expected.method || cmd.upsert != expected.upsert || len(cmd.merged) != expected.merged {
+					t.Fatalf("command %d: expected %v upsert=%v merged=%d, got %v upsert=%v merged=%d",
+						i, expected.method, expected.upsert, expected.merged, cmd.Record.Method, cmd.upsert, len(cmd.merged))
+				}
+
+				values := getRecordValues(cmd.Record)
+				if !reflect.DeepEqual(values, expected.fields) {
+					t.Fatalf("command %d: expected fields %v, got %v", i, expected.fields, values)
+				}
+			}
+
+			// Merged command is positioned at the last event
+			last := results[len(results)-1]
+			if len(last.merged) > 0 && last.Sequence != uint64(len(test.records)) {
+				t.Fatalf("expected sequence %d, got %d", len(test.records), last.Sequence)
+			}
+
+			// Original records are not modified
+			if !reflect.DeepEqual(getRecordValues(test.records[0]), original) {
+				t.Fatal("original record was modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultConnection is the connection configured in [mongodb] section
+const DefaultConnection = "default"
+
+// ConnectionManager holds connections to MongoDB, named connections are
+// configured in [connections.<name>] sections with the same settings as [mongodb].
+type ConnectionManager struct {
+	connectors map[string]*MongoDBConnector
+}
+
+func NewConnectionManager() *ConnectionManager {
+
+	manager := &ConnectionManager{
+		connectors: make(map[string]*MongoDBConnector),
+	}
+
+	manager.connectors[DefaultConnection] = NewMongoDBConnector(DefaultConnection, "mongodb")
+
+	for name := range viper.GetStringMap("connections") {
+		if name == DefaultConnection {
+			continue
+		}
+
+		manager.connectors[name] = NewMongoDBConnector(name, "connections."+name)
+	}
+
+	return manager
+}
+
+// Connect connects to all databases
+func (manager *ConnectionManager) Connect() error {
+
+	for _, name := range manager.getNames() {
+		err := manager.connectors[name].Connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InitializeTargets creates collections and indexes of targets on their connections
+func (manager *ConnectionManager) InitializeTargets(targets []*rule.Target) error {
+
+	// Group targets by connection
+	groups := make(map[*MongoDBConnector][]*rule.Target)
+	for _, target := range targets {
+
+		name, err := rule.ParseCollectionName(target.Collection)
+		if err != nil {
+			return err
+		}
+
+		connector, err := manager.GetConnector(name.Connection)
+		if err != nil {
+			return fmt.Errorf("Target %s: %v", target.Collection, err)
+		}
+
+		groups[connector] = append(groups[connector], target)
+	}
+
+	for connector, ts := range groups {
+		err := connector.InitializeCollections(ts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (manager *ConnectionManager) Disconnect() error {
+
+	var lastErr error
+	for _, connector := range manager.connectors {
+		err := connector.Disconnect()
+		if err != nil {
+			log.Error(err)
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// Ping checks all connections
+func (manager *ConnectionManager) Ping(ctx context.Context) error {
+
+	for _, name := range manager.getNames() {
+
+		// Still connecting at startup
+		connector := manager.connectors[name]
+		if !connector.IsConnected() {
+			return fmt.Errorf("%s: %v", name, ErrNotConnected)
+		}
+
+		client, release := connector.Acquire()
+		err := client.Ping(ctx, nil)
+		release()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (manager *ConnectionManager) GetDefault() *MongoDBConnector {
+	return manager.connectors[DefaultConnection]
+}
+
+func (manager *ConnectionManager) GetConnector(name string) (*MongoDBConnector, error) {
+
+	if len(name) == 0 {
+		return manager.GetDefault(), nil
+	}
+
+	connector, ok := manager.connectors[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown connection: %s", name)
+	}
+
+	return connector, nil
+}
+
+// GetCollection returns collection of target name like "connection/database/collection".
+// Client of collection is not closed by rotation until release is called.
+func (manager *ConnectionManager) GetCollection(target string) (*MongoDBConnector, *mongo.Collection, func(), error) {
+
+	name, err := rule.ParseCollectionName(target)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	connector, err := manager.GetConnector(name.Connection)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	client, release := connector.Acquire()
+
+	return connector, connector.getDatabase(client, name.Database).Collection(name.Collection), release, nil
+}
+
+// collectionLabels identifies collection in metrics by connection, database and collection
+type collectionLabels []string
+
+func (labels collectionLabels) with(values ...string) []string {
+	return append(append(make([]string, 0, len(labels)+len(values)), labels...), values...)
+}
+
+func (manager *ConnectionManager) getLabels(target string) collectionLabels {
+
+	name, err := rule.ParseCollectionName(target)
+	if err != nil {
+		return collectionLabels{"", "", target}
+	}
+
+	connector, err := manager.GetConnector(name.Connection)
+	if err != nil {
+		return collectionLabels{name.Connection, name.Database, name.Collection}
+	}
+
+	database := name.Database
+	if len(database) == 0 {
+		database = connector.dbname
+	}
+
+	return collectionLabels{connector.name, database, name.Collection}
+}
+
+func (manager *ConnectionManager) getNames() []string {
+
+	names := make([]string, 0, len(manager.connectors))
+	for name := range manager.connectors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,59 @@
+package writer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetLabels(t *testing.T) {
+
+	manager := &ConnectionManager{
+		connectors: map[string]*MongoDBConnector{
+			DefaultConnection: {name: DefaultConnection, dbname: "gravity"},
+			"archive":         {name: "archive", dbname: "archive"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		expected []string
+	}{
+		{"collection", "users", []string{"default", "gravity", "users"}},
+		{"database", "crm/users", []string{"default", "crm", "users"}},
+		{"connection", "archive/crm/users", []string{"archive", "crm", "users"}},
+		{"unknown connection", "other/crm/users", []string{"other", "crm", "users"}},
+		{"invalid", "crm//users", []string{"", "", "crm//users"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			labels := manager.getLabels(test.target)
+			if !reflect.DeepEqual([]string(labels), test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, labels)
+			}
+		})
+	}
+
+	labels := manager.getLabels("users")
+	withClass := labels.with("retryable")
+	if !reflect.DeepEqual(withClass, []string{"default", "gravity", "users", "retryable"}) || len(labels) != 3 {
+		t.Fatalf("unexpected labels %v", withClass)
+	}
+}
+
+func TestPingBeforeConnected(t *testing.T) {
+
+	manager := &ConnectionManager{
+		connectors: map[string]*MongoDBConnector{
+			DefaultConnection: NewMongoDBConnector(DefaultConnection, "mongodb"),
+		},
+	}
+
+	err := manager.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), ErrNotConnected.Error()) {
+		t.Fatalf("expected %v, got %v", ErrNotConnected, err)
+	}
+}
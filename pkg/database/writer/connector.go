@@ -2,9 +2,8 @@ package writer
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
+	"errors"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -12,81 +11,175 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var ErrNotConnected = errors.New("Not connected to MongoDB")
+
+// clientRef counts writes which are using client, so that client can be closed
+// after all of them finished when it was replaced by rotation.
+type clientRef struct {
+	client   *mongo.Client
+	inflight sync.WaitGroup
+}
+
 type MongoDBConnector struct {
-	client *mongo.Client
+	name        string
+	prefix      string
+	dbname      string
+	current     *clientRef
+	mutex       sync.RWMutex
+	indexed     sync.Map
+	fingerprint string
+	stop        chan struct{}
 }
 
-func NewMongoDBConnector() *MongoDBConnector {
-	return &MongoDBConnector{}
+// NewMongoDBConnector creates connector with settings in specific section of config
+func NewMongoDBConnector(name string, prefix string) *MongoDBConnector {
+	return &MongoDBConnector{
+		name:   name,
+		prefix: prefix,
+		dbname: viper.GetString(prefix + ".dbname"),
+		stop:   make(chan struct{}),
+	}
 }
 
 func (mdb *MongoDBConnector) Connect() error {
 
-	uri := viper.GetString("mongodb.uri")
+	// Files which contain credentials and certificates
+	fingerprint, err := mdb.getFingerprint()
+	if err != nil {
+		return err
+	}
+
+	client, err := mdb.newClient()
+	if err != nil {
+		return err
+	}
+
+	// Health checks might be acquiring client already
+	mdb.mutex.Lock()
+	mdb.current = &clientRef{
+		client: client,
+	}
+	mdb.mutex.Unlock()
+	mdb.fingerprint = fingerprint
 
 	log.WithFields(log.Fields{
-		"uri": uri,
+		"connection": mdb.name,
+	}).Info("Connected to MongoDB Successfully")
+
+	go mdb.watch()
+
+	return nil
+}
+
+func (mdb *MongoDBConnector) newClient() (*mongo.Client, error) {
+
+	uri := viper.GetString(mdb.prefix + ".uri")
+
+	// Password in URI should never be logged as well
+	if matches := uriPasswordRegexp.FindStringSubmatch(uri); matches != nil {
+		RedactSecret(matches[2])
+	}
+
+	log.WithFields(log.Fields{
+		"connection": mdb.name,
+		"uri":        RedactURI(uri),
 	}).Info("Connect to MongoDB")
 
 	// Set client options
 	clientOptions := options.Client().ApplyURI(uri)
 
-	// Load CA file
-	caFile := viper.GetString("mongodb.ca_file")
-	if len(caFile) > 0 {
-		tlsConfig, err := mdb.LoadCert(caFile)
-		if err != nil {
-			return err
-		}
+	// TLS
+	tlsConfig, err := mdb.LoadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
 
+	if tlsConfig != nil {
 		clientOptions.SetTLSConfig(tlsConfig)
 	}
 
+	// Credential which is not in URI
+	credential, err := mdb.loadCredential(clientOptions.Auth, tlsConfig != nil && len(tlsConfig.Certificates) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential != nil {
+		clientOptions.SetAuth(*credential)
+	}
+
 	// Connect to MongoDB
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	mdb.client = client
-
 	// Check the connection
 	err = client.Ping(context.TODO(), nil)
 	if err != nil {
-		return err
+		client.Disconnect(context.Background())
+		return nil, err
 	}
 
-	log.Info("Connected to MongoDB Successfully")
-
-	// Initializing database
-	return mdb.InitializeDatabase()
+	return client, nil
 }
 
-func (mdb *MongoDBConnector) LoadCert(caFile string) (*tls.Config, error) {
+func (mdb *MongoDBConnector) Disconnect() error {
 
-	// Load CA cert
-	caCert, err := ioutil.ReadFile(caFile)
-	if err != nil {
-		return nil, err
+	// Never connected
+	if !mdb.IsConnected() {
+		return nil
 	}
 
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
+	log.WithFields(log.Fields{
+		"connection": mdb.name,
+	}).Info("Disconnecting from MongoDB")
 
-	// Setup HTTPS client
-	tlsConfig := &tls.Config{
-		RootCAs: caCertPool,
-	}
-	tlsConfig.BuildNameToCertificate()
+	close(mdb.stop)
+
+	mdb.mutex.RLock()
+	client := mdb.current.client
+	mdb.mutex.RUnlock()
 
-	return tlsConfig, nil
+	return client.Disconnect(context.Background())
 }
 
-func (mdb *MongoDBConnector) InitializeDatabase() error {
+// IsConnected returns true once connection was established
+func (mdb *MongoDBConnector) IsConnected() bool {
 
-	return nil
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+
+	return mdb.current != nil
+}
+
+// Acquire returns current client which is not going to be closed by rotation
+// until release is called.
+func (mdb *MongoDBConnector) Acquire() (*mongo.Client, func()) {
+
+	mdb.mutex.RLock()
+	defer mdb.mutex.RUnlock()
+
+	ref := mdb.current
+	ref.inflight.Add(1)
+
+	return ref.client, ref.inflight.Done
 }
 
-func (mdb *MongoDBConnector) GetClient() *mongo.Client {
-	return mdb.client
+// AcquireDatabase returns specific database, default database of connection
+// is used if name is empty. Client is not closed by rotation until release is called.
+func (mdb *MongoDBConnector) AcquireDatabase(name string) (*mongo.Database, func()) {
+
+	client, release := mdb.Acquire()
+
+	return mdb.getDatabase(client, name), release
+}
+
+func (mdb *MongoDBConnector) getDatabase(client *mongo.Client, name string) *mongo.Database {
+
+	if len(name) == 0 {
+		name = mdb.dbname
+	}
+
+	return client.Database(name)
 }
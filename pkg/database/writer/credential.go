@@ -0,0 +1,154 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	AuthMechanismSCRAMSHA1   = "SCRAM-SHA-1"
+	AuthMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	AuthMechanismX509        = "MONGODB-X509"
+	AuthMechanismAWS         = "MONGODB-AWS"
+)
+
+// Time limit of command which provides secret
+var SecretCommandTimeout = 30 * time.Second
+
+// loadCredential returns credential configured separately from URI or merged into it, it returns
+// nil if credential in URI should be used as it is.
+func (mdb *MongoDBConnector) loadCredential(current *options.Credential, hasClientCert bool) (*options.Credential, error) {
+
+	username, err := mdb.readSecret("username")
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := mdb.readSecret("password")
+	if err != nil {
+		return nil, err
+	}
+
+	mechanism := viper.GetString(mdb.prefix + ".auth_mechanism")
+	authSource := viper.GetString(mdb.prefix + ".auth_source")
+
+	credential := &options.Credential{
+		Username:    username,
+		Password:    password,
+		PasswordSet: len(password) > 0,
+	}
+
+	// Settings apply to credential in URI if username and password are not
+	// configured separately.
+	fromURI := false
+	if len(username) == 0 && len(password) == 0 {
+
+		if current == nil && len(mechanism) == 0 {
+			return nil, nil
+		}
+
+		if current != nil {
+			if len(mechanism) == 0 && len(authSource) == 0 {
+				return nil, nil
+			}
+
+			merged := *current
+			credential = &merged
+			fromURI = true
+		}
+	}
+
+	RedactSecret(password)
+
+	if len(authSource) > 0 {
+		credential.AuthSource = authSource
+	}
+
+	// Mechanism in URI is kept
+	if fromURI && len(mechanism) == 0 {
+		return credential, nil
+	}
+
+	credential.AuthMechanism = mechanism
+
+	switch mechanism {
+	case "", AuthMechanismSCRAMSHA1, AuthMechanismSCRAMSHA256:
+		if len(credential.Username) == 0 {
+			return nil, fmt.Errorf("Username is required by %s authentication", mdb.name)
+		}
+	case AuthMechanismX509:
+		if !hasClientCert {
+			return nil, fmt.Errorf("Client certificate is required by %s", AuthMechanismX509)
+		}
+
+		credential.AuthSource = "$external"
+		credential.PasswordSet = false
+	case AuthMechanismAWS:
+
+		// Credential is obtained from environment or instance role if access key is not set
+		credential.AuthSource = "$external"
+
+		token, err := mdb.readSecret("aws_session_token")
+		if err != nil {
+			return nil, err
+		}
+
+		if len(token) > 0 {
+			RedactSecret(token)
+			credential.AuthMechanismProperties = map[string]string{
+				"AWS_SESSION_TOKEN": token,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported auth mechanism: %s", mechanism)
+	}
+
+	return credential, nil
+}
+
+// readSecret reads setting from file, environment variable or output of command
+// if "<key>_file", "<key>_env" or "<key>_command" is specified.
+func (mdb *MongoDBConnector) readSecret(key string) (string, error) {
+
+	name := mdb.prefix + "." + key
+
+	if filename := viper.GetString(name + "_file"); len(filename) > 0 {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read %s: %v", key, err)
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if env := viper.GetString(name + "_env"); len(env) > 0 {
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s for %s is not set", env, key)
+		}
+
+		return value, nil
+	}
+
+	if command := viper.GetString(name + "_command"); len(command) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), SecretCommandTimeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("Failed to get %s from command: %v", key, err)
+		}
+
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	return viper.GetString(name), nil
+}
@@ -0,0 +1,100 @@
+package writer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestLoadCredential(t *testing.T) {
+
+	uriCredential := &options.Credential{
+		Username:    "fred",
+		Password:    "secret",
+		PasswordSet: true,
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		current  *options.Credential
+		expected *options.Credential
+		fails    bool
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name:    "credential in URI",
+			current: uriCredential,
+		},
+		{
+			name:     "auth source for credential in URI",
+			settings: map[string]string{"auth_source": "admin"},
+			current:  uriCredential,
+			expected: &options.Credential{Username: "fred", Password: "secret", PasswordSet: true, AuthSource: "admin"},
+		},
+		{
+			name:     "auth mechanism for credential in URI",
+			settings: map[string]string{"auth_mechanism": "SCRAM-SHA-256", "auth_source": "admin"},
+			current:  uriCredential,
+			expected: &options.Credential{Username: "fred", Password: "secret", PasswordSet: true, AuthSource: "admin", AuthMechanism: "SCRAM-SHA-256"},
+		},
+		{
+			name:     "auth mechanism without credential",
+			settings: map[string]string{"auth_mechanism": "SCRAM-SHA-256"},
+			fails:    true,
+		},
+		{
+			name:     "auth source without credential",
+			settings: map[string]string{"auth_source": "admin"},
+		},
+		{
+			name:     "username and password",
+			settings: map[string]string{"username": "armani", "password": "s3cr3t-pw", "auth_source": "admin"},
+			current:  uriCredential,
+			expected: &options.Credential{Username: "armani", Password: "s3cr3t-pw", PasswordSet: true, AuthSource: "admin"},
+		},
+		{
+			name:     "password without username",
+			settings: map[string]string{"password": "s3cr3t-pw"},
+			fails:    true,
+		},
+		{
+			name:     "unsupported mechanism",
+			settings: map[string]string{"username": "armani", "auth_mechanism": "PLAIN"},
+			fails:    true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			prefix := fmt.Sprintf("credential_test_%d", i)
+			for key, value := range test.settings {
+				viper.Set(prefix+"."+key, value)
+			}
+
+			mdb := &MongoDBConnector{name: "test", prefix: prefix}
+			credential, err := mdb.loadCredential(test.current, false)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", credential)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(credential, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, credential)
+			}
+		})
+	}
+}
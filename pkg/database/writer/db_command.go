@@ -1,15 +1,25 @@
 package writer
 
-import gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+import (
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+)
 
 type DBCommand struct {
 	PipelineID uint64
 	Sequence   uint64
 	Reference  interface{}
 	Record     *gravity_sdk_types_record.Record
+	Target     *rule.Target
 	QueryStr   string
 	Args       map[string]interface{}
-	Tables     []string
+
+	// Truncation requested by writer itself rather than gravity
+	done chan error
+
+	// Commands coalesced into this command
+	merged []*DBCommand
+	upsert bool
 }
 
 func (cmd *DBCommand) GetReference() interface{} {
@@ -23,7 +33,3 @@ func (cmd *DBCommand) GetPipelineID() uint64 {
 func (cmd *DBCommand) GetSequence() uint64 {
 	return cmd.Sequence
 }
-
-func (cmd *DBCommand) GetTables() []string {
-	return cmd.Tables
-}
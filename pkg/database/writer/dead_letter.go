@@ -0,0 +1,145 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	DeadLetterTypeNone       = "none"
+	DeadLetterTypeCollection = "collection"
+	DeadLetterTypeFile       = "file"
+)
+
+type DeadLetterRecord struct {
+	EventName  string                 `json:"eventName" bson:"eventName"`
+	Table      string                 `json:"table" bson:"table"`
+	Method     string                 `json:"method" bson:"method"`
+	PrimaryKey string                 `json:"primaryKey" bson:"primaryKey"`
+	Fields     map[string]interface{} `json:"fields" bson:"fields"`
+	Raw        []byte                 `json:"raw" bson:"raw"`
+}
+
+type DeadLetter struct {
+	PipelineID uint64            `json:"pipelineID" bson:"pipelineID"`
+	Sequence   uint64            `json:"sequence" bson:"sequence"`
+	Record     *DeadLetterRecord `json:"record" bson:"record"`
+	ErrorCode  int               `json:"errorCode" bson:"errorCode"`
+	Error      string            `json:"error" bson:"error"`
+	FailedAt   time.Time         `json:"failedAt" bson:"failedAt"`
+}
+
+type DeadLetterQueue struct {
+	connector  *MongoDBConnector
+	queueType  string
+	collection string
+	filename   string
+	file       *os.File
+	mutex      sync.Mutex
+}
+
+func NewDeadLetterQueue(connector *MongoDBConnector) *DeadLetterQueue {
+
+	viper.SetDefault("deadLetter.type", DeadLetterTypeNone)
+	viper.SetDefault("deadLetter.collection", "_gravity_dead_letters")
+	viper.SetDefault("deadLetter.file", "./dead_letters.log")
+
+	return &DeadLetterQueue{
+		connector:  connector,
+		queueType:  viper.GetString("deadLetter.type"),
+		collection: viper.GetString("deadLetter.collection"),
+		filename:   viper.GetString("deadLetter.file"),
+	}
+}
+
+func (dlq *DeadLetterQueue) Init() error {
+
+	log.WithFields(log.Fields{
+		"type": dlq.queueType,
+	}).Info("Initializing dead letter queue")
+
+	switch dlq.queueType {
+	case DeadLetterTypeNone, DeadLetterTypeCollection:
+		return nil
+	case DeadLetterTypeFile:
+		f, err := os.OpenFile(dlq.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		dlq.file = f
+
+		return nil
+	}
+
+	return fmt.Errorf("Unknown dead letter type: %s", dlq.queueType)
+}
+
+func (dlq *DeadLetterQueue) Close() error {
+
+	if dlq.file == nil {
+		return nil
+	}
+
+	return dlq.file.Close()
+}
+
+func (dlq *DeadLetterQueue) Push(cmd *DBCommand, we mongo.WriteError) error {
+
+	record := cmd.Record
+
+	raw, _ := gravity_sdk_types_record.Marshal(record)
+
+	deadLetter := &DeadLetter{
+		PipelineID: cmd.PipelineID,
+		Sequence:   cmd.Sequence,
+		Record: &DeadLetterRecord{
+			EventName:  record.EventName,
+			Table:      record.Table,
+			Method:     record.Method.String(),
+			PrimaryKey: record.PrimaryKey,
+			Fields:     gravity_sdk_types_record.ConvertFieldsToMap(record.Fields),
+			Raw:        raw,
+		},
+		ErrorCode: we.Code,
+		Error:     we.Message,
+		FailedAt:  time.Now(),
+	}
+
+	log.WithFields(log.Fields{
+		"table":  record.Table,
+		"method": deadLetter.Record.Method,
+		"code":   we.Code,
+	}).Errorf("Dropped record due to permanent error: %s", we.Message)
+
+	switch dlq.queueType {
+	case DeadLetterTypeCollection:
+		mdb, release := dlq.connector.AcquireDatabase("")
+		defer release()
+
+		_, err := mdb.Collection(dlq.collection).InsertOne(context.Background(), deadLetter)
+		return err
+	case DeadLetterTypeFile:
+		data, err := json.Marshal(deadLetter)
+		if err != nil {
+			return err
+		}
+
+		dlq.mutex.Lock()
+		defer dlq.mutex.Unlock()
+
+		_, err = dlq.file.Write(append(data, '\n'))
+		return err
+	}
+
+	return nil
+}
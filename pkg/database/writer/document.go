@@ -0,0 +1,138 @@
+package writer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// buildDocument places values into nested documents and arrays declared by
+// target. Primary key is always kept at top level to be used in filters.
+func buildDocument(target *rule.Target, values map[string]interface{}, keys []string) (map[string]interface{}, error) {
+
+	if !target.HasStructure() {
+		return values, nil
+	}
+
+	doc := make(map[string]interface{}, len(values))
+	arrays := make(map[string]bson.A)
+
+	for name, value := range values {
+
+		if isPrimaryKey(keys, name) {
+			doc[name] = value
+			continue
+		}
+
+		element := target.GetArrayElement(name)
+		if element == nil {
+			err := setPath(doc, target.GetPath(name), value)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		arr, ok := arrays[element.Array]
+		if !ok {
+			arr = make(bson.A, len(target.Arrays[element.Array]))
+			arrays[element.Array] = arr
+		}
+
+		arr[element.Index] = value
+	}
+
+	for name, arr := range arrays {
+		err := setPath(doc, strings.Split(name, "."), arr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// buildUpdate returns fields to be updated with dotted paths so that sibling
+// fields of nested documents are not overwritten.
+func buildUpdate(target *rule.Target, values map[string]interface{}) bson.M {
+
+	if !target.HasStructure() {
+		return bson.M(values)
+	}
+
+	set := make(bson.M, len(values))
+	arrays := make(map[string]map[int]interface{})
+
+	for name, value := range values {
+
+		element := target.GetArrayElement(name)
+		if element == nil {
+			set[strings.Join(target.GetPath(name), ".")] = value
+			continue
+		}
+
+		elements, ok := arrays[element.Array]
+		if !ok {
+			elements = make(map[int]interface{})
+			arrays[element.Array] = elements
+		}
+
+		elements[element.Index] = value
+	}
+
+	for name, elements := range arrays {
+
+		// Replace the whole array if we have all elements
+		if len(elements) == len(target.Arrays[name]) {
+			arr := make(bson.A, len(elements))
+			for i, value := range elements {
+				arr[i] = value
+			}
+
+			set[name] = arr
+			continue
+		}
+
+		for i, value := range elements {
+			set[fmt.Sprintf("%s.%d", name, i)] = value
+		}
+	}
+
+	return set
+}
+
+func setPath(doc map[string]interface{}, path []string, value interface{}) error {
+
+	cur := doc
+	for i, part := range path[:len(path)-1] {
+
+		v, ok := cur[part]
+		if !ok {
+			sub := make(map[string]interface{})
+			cur[part] = sub
+			cur = sub
+			continue
+		}
+
+		switch sub := v.(type) {
+		case map[string]interface{}:
+			cur = sub
+		case bson.M:
+			cur = sub
+		default:
+			return fmt.Errorf("Conflict at path %s: not a document", strings.Join(path[:i+1], "."))
+		}
+	}
+
+	name := path[len(path)-1]
+	if _, ok := cur[name]; ok {
+		return fmt.Errorf("Conflict at path %s: value already exists", strings.Join(path, "."))
+	}
+
+	cur[name] = value
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package writer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func newTestTarget(data string) *rule.Target {
+
+	var target rule.Target
+	err := json.Unmarshal([]byte(data), &target)
+	if err != nil {
+		panic(err)
+	}
+
+	return &target
+}
+
+func TestBuildDocument(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		rule     string
+		keys     []string
+		values   map[string]interface{}
+		expected map[string]interface{}
+		fails    bool
+	}{
+		{
+			name:     "flat",
+			rule:     `{"collection":"users"}`,
+			values:   map[string]interface{}{"id": 1, "a.b": 2},
+			expected: map[string]interface{}{"id": 1, "a.b": 2},
+		},
+		{
+			name:   "paths",
+			rule:   `{"collection":"users","paths":{"city":"address.city","zip":"address.zip"}}`,
+			values: map[string]interface{}{"id": 1, "city": "Taipei", "zip": "100"},
+			expected: map[string]interface{}{
+				"id":      1,
+				"address": map[string]interface{}{"city": "Taipei", "zip": "100"},
+			},
+		},
+		{
+			name:   "dotted fields",
+			rule:   `{"collection":"users","nestDottedFields":true}`,
+			values: map[string]interface{}{"id": 1, "profile.name": "fred", "profile.age": 30},
+			expected: map[string]interface{}{
+				"id":      1,
+				"profile": map[string]interface{}{"name": "fred", "age": 30},
+			},
+		},
+		{
+			name:   "arrays",
+			rule:   `{"collection":"users","arrays":{"contact.phones":["phone1","phone2"]}}`,
+			values: map[string]interface{}{"id": 1, "phone2": "456", "phone1": "123"},
+			expected: map[string]interface{}{
+				"id":      1,
+				"contact": map[string]interface{}{"phones": bson.A{"123", "456"}},
+			},
+		},
+		{
+			name:   "missing array element",
+			rule:   `{"collection":"users","arrays":{"phones":["phone1","phone2"]}}`,
+			values: map[string]interface{}{"id": 1, "phone2": "456"},
+			expected: map[string]interface{}{
+				"id":     1,
+				"phones": bson.A{nil, "456"},
+			},
+		},
+		{
+			name:     "primary key stays at top level",
+			rule:     `{"collection":"users","nestDottedFields":true}`,
+			keys:     []string{"user.id"},
+			values:   map[string]interface{}{"user.id": 1, "user.name": "fred"},
+			expected: map[string]interface{}{"user.id": 1, "user": map[string]interface{}{"name": "fred"}},
+		},
+		{
+			name:   "conflict with value",
+			rule:   `{"collection":"users","nestDottedFields":true}`,
+			values: map[string]interface{}{"id": 1, "a": 1, "a.b": 2},
+			fails:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			keys := test.keys
+			if keys == nil {
+				keys = []string{"id"}
+			}
+
+			doc, err := buildDocument(newTestTarget(test.rule), test.values, keys)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %v", doc)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(doc, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, doc)
+			}
+		})
+	}
+}
+
+func TestBuildUpdate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		rule     string
+		values   map[string]interface{}
+		expected bson.M
+	}{
+		{
+			name:     "flat",
+			rule:     `{"collection":"users"}`,
+			values:   map[string]interface{}{"name": "fred", "a.b": 2},
+			expected: bson.M{"name": "fred", "a.b": 2},
+		},
+		{
+			name:     "paths",
+			rule:     `{"collection":"users","paths":{"city":"address.city"}}`,
+			values:   map[string]interface{}{"name": "fred", "city": "Taipei"},
+			expected: bson.M{"name": "fred", "address.city": "Taipei"},
+		},
+		{
+			name:     "dotted fields",
+			rule:     `{"collection":"users","nestDottedFields":true}`,
+			values:   map[string]interface{}{"profile.name": "fred"},
+			expected: bson.M{"profile.name": "fred"},
+		},
+		{
+			name:     "all array elements",
+			rule:     `{"collection":"users","arrays":{"phones":["phone1","phone2"]}}`,
+			values:   map[string]interface{}{"phone1": "123", "phone2": "456"},
+			expected: bson.M{"phones": bson.A{"123", "456"}},
+		},
+		{
+			name:     "some array elements",
+			rule:     `{"collection":"users","arrays":{"phones":["phone1","phone2","phone3"]}}`,
+			values:   map[string]interface{}{"phone1": "123", "phone3": "789"},
+			expected: bson.M{"phones.0": "123", "phones.2": "789"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			set := buildUpdate(newTestTarget(test.rule), test.values)
+			if !reflect.DeepEqual(set, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, set)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Error code of duplicate key
+const duplicateKeyCode = 11000
+
+func isEmbedded(cmd *DBCommand) bool {
+	return cmd.Target != nil && cmd.Target.Embed != nil
+}
+
+// prepareEmbedModel turns record into an update of array in parent document
+// which is identified by foreign key of record.
+func prepareEmbedModel(cmd *DBCommand, values map[string]interface{}, keys []string) (mongo.WriteModel, error) {
+
+	embed := cmd.Target.Embed
+
+	if !hasPrimaryKey(cmd.Record, keys) {
+		return nil, fmt.Errorf("Primary key is required for embedded record")
+	}
+
+	// Records are dispatched to shards by foreign key, so it is required by
+	// all events to keep them in order.
+	fk, ok := values[embed.ForeignKey]
+	if !ok {
+		return nil, fmt.Errorf("Foreign key %s is required for embedded record", embed.ForeignKey)
+	}
+
+	// Element in array is identified by primary key of record
+	match := getEmbedMatch(values, keys)
+	filter := bson.D{{Key: embed.GetParentKey(), Value: fk}}
+
+	switch cmd.Record.Method {
+	case gravity_sdk_types_record.Method_INSERT:
+		doc, err := buildDocument(cmd.Target, values, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		// Do not push the same record twice. Parent document which is not
+		// there yet is created with the array only, if record was pushed
+		// already it fails with duplicate key.
+		filter = append(filter, bson.E{
+			Key:   embed.Field,
+			Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$elemMatch", Value: match}}}},
+		})
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$push": bson.M{embed.Field: doc}}).
+			SetUpsert(true), nil
+
+	case gravity_sdk_types_record.Method_UPDATE:
+		filter = append(filter, bson.E{
+			Key:   embed.Field,
+			Value: bson.D{{Key: "$elemMatch", Value: match}},
+		})
+
+		for _, key := range keys {
+			delete(values, key)
+		}
+
+		// Nothing to update other than keys
+		_, hasFK := values[embed.ForeignKey]
+		if len(values) == 0 || (hasFK && len(values) == 1) {
+			return nil, nil
+		}
+
+		// Update matched element only
+		set := make(bson.M, len(values))
+		for path, value := range buildUpdate(cmd.Target, values) {
+			set[embed.Field+".$."+path] = value
+		}
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": set}), nil
+
+	case gravity_sdk_types_record.Method_DELETE:
+		filter = append(filter, bson.E{
+			Key:   embed.Field,
+			Value: bson.D{{Key: "$elemMatch", Value: match}},
+		})
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$pull": bson.M{embed.Field: match}}), nil
+	}
+
+	return nil, nil
+}
+
+func getEmbedMatch(values map[string]interface{}, keys []string) bson.D {
+
+	match := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		match = append(match, bson.E{Key: key, Value: values[key]})
+	}
+
+	return match
+}
+
+// prepareParentModel merges parent record into document which might be
+// created by embedded records already, arrays of embedded records are kept.
+func prepareParentModel(cmd *DBCommand, values map[string]interface{}, keys []string, useID bool, fields []string) mongo.WriteModel {
+
+	filter := getKeyFilter(values, keys, useID)
+
+	// Primary key is set by filter
+	for _, key := range keys {
+		delete(values, key)
+	}
+
+	set := make(bson.M, len(values))
+	for path, value := range buildUpdate(cmd.Target, values) {
+		if !isEmbedPath(fields, path) {
+			set[path] = value
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	// Soft-deleted document is alive again
+	if softDelete := getSoftDelete(cmd); softDelete != nil {
+		update["$unset"] = bson.M{
+			softDelete.GetField():          "",
+			softDelete.GetTimestampField(): "",
+		}
+	}
+
+	// Document is created with primary key only
+	if len(update) == 0 {
+		update["$setOnInsert"] = filter
+	}
+
+	return mongo.NewUpdateOneModel().
+		SetFilter(filter).
+		SetUpdate(update).
+		SetUpsert(true)
+}
+
+func isEmbedPath(fields []string, path string) bool {
+
+	for _, field := range fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPushed checks whether embedded record exists in array of parent document,
+// it tells if duplicate key was caused by pushing the same record again.
+func isPushed(collection *mongo.Collection, cmd *DBCommand, we mongo.WriteError) (bool, error) {
+
+	if !isEmbedded(cmd) || cmd.Record.Method != gravity_sdk_types_record.Method_INSERT || we.Code != duplicateKeyCode {
+		return false, nil
+	}
+
+	values, err := convertFields(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	embed := cmd.Target.Embed
+	filter := bson.D{
+		{Key: embed.GetParentKey(), Value: values[embed.ForeignKey]},
+		{Key: embed.Field, Value: bson.D{{Key: "$elemMatch", Value: getEmbedMatch(values, getPrimaryKeys(cmd))}}},
+	}
+
+	count, err := collection.CountDocuments(context.Background(), filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
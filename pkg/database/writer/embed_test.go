@@ -0,0 +1,228 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newEmbedRecord(method gravity_sdk_types_record.Method, fields ...interface{}) *gravity_sdk_types_record.Record {
+	record := newRecord(method, "", fields...)
+	record.Table = "orders"
+	return record
+}
+
+func TestPrepareEmbedModel(t *testing.T) {
+
+	target := newTestTarget(`{"collection":"orders","primaryKeys":["item_id"],"embed":{"field":"items","foreignKey":"order_id","parentKey":"id"}}`)
+	match := bson.D{{Key: "item_id", Value: int64(2)}}
+
+	tests := []struct {
+		name     string
+		record   *gravity_sdk_types_record.Record
+		expected *mongo.UpdateOneModel
+		skipped  bool
+		fails    bool
+	}{
+		{
+			name:   "insert",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "item_id", int64(2), "order_id", int64(1), "qty", int64(3)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{
+					{Key: "id", Value: int64(1)},
+					{Key: "items", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$elemMatch", Value: match}}}}},
+				}).
+				SetUpdate(bson.M{"$push": bson.M{"items": map[string]interface{}{"item_id": int64(2), "order_id": int64(1), "qty": int64(3)}}}).
+				SetUpsert(true),
+		},
+		{
+			name:   "update",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_UPDATE, "item_id", int64(2), "order_id", int64(1), "qty", int64(4)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{
+					{Key: "id", Value: int64(1)},
+					{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: match}}},
+				}).
+				SetUpdate(bson.M{"$set": bson.M{"items.$.order_id": int64(1), "items.$.qty": int64(4)}}),
+		},
+		{
+			name:    "update keys only",
+			record:  newEmbedRecord(gravity_sdk_types_record.Method_UPDATE, "item_id", int64(2), "order_id", int64(1)),
+			skipped: true,
+		},
+		{
+			name:   "delete",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_DELETE, "item_id", int64(2), "order_id", int64(1)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{
+					{Key: "id", Value: int64(1)},
+					{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: match}}},
+				}).
+				SetUpdate(bson.M{"$pull": bson.M{"items": match}}),
+		},
+		{
+			name:   "insert without foreign key",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "item_id", int64(2), "qty", int64(3)),
+			fails:  true,
+		},
+		{
+			name:   "update without foreign key",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_UPDATE, "item_id", int64(2), "qty", int64(3)),
+			fails:  true,
+		},
+		{
+			name:   "delete without foreign key",
+			record: newEmbedRecord(gravity_sdk_types_record.Method_DELETE, "item_id", int64(2)),
+			fails:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writer := newTestWriter(WriteModeInsert)
+			model, err := writer.prepareModel(&DBCommand{Record: test.record, Target: target})
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %v", model)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.skipped {
+				if model != nil {
+					t.Fatalf("expected command to be skipped, got %v", model)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(model, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, model)
+			}
+		})
+	}
+}
+
+func TestPrepareParentModel(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		rule      string
+		writeMode string
+		record    *gravity_sdk_types_record.Record
+		expected  *mongo.UpdateOneModel
+	}{
+		{
+			name:      "insert mode",
+			rule:      `{"collection":"orders","primaryKeys":["id"]}`,
+			writeMode: WriteModeInsert,
+			record:    newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "id", int64(1), "customer", "fred", "items", "ignored"),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$set": bson.M{"customer": "fred"}}).
+				SetUpsert(true),
+		},
+		{
+			name:      "upsert mode with primary key as _id",
+			rule:      `{"collection":"orders","primaryKeys":["id"],"primaryKeyAsID":true}`,
+			writeMode: WriteModeUpsert,
+			record:    newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "id", int64(1), "customer", "fred"),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "_id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$set": bson.M{"customer": "fred"}}).
+				SetUpsert(true),
+		},
+		{
+			name:      "nested fields",
+			rule:      `{"collection":"orders","primaryKeys":["id"],"nestDottedFields":true}`,
+			writeMode: WriteModeUpsert,
+			record:    newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "id", int64(1), "customer.name", "fred", "items.count", int64(2)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$set": bson.M{"customer.name": "fred"}}).
+				SetUpsert(true),
+		},
+		{
+			name:      "soft delete",
+			rule:      `{"collection":"orders","primaryKeys":["id"],"softDelete":{"field":"deleted"}}`,
+			writeMode: WriteModeUpsert,
+			record:    newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "id", int64(1), "customer", "fred"),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{
+					"$set":   bson.M{"customer": "fred"},
+					"$unset": bson.M{"deleted": "", "_deletedAt": ""},
+				}).
+				SetUpsert(true),
+		},
+		{
+			name:      "primary key only",
+			rule:      `{"collection":"orders","primaryKeys":["id"]}`,
+			writeMode: WriteModeUpsert,
+			record:    newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "id", int64(1)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$setOnInsert": bson.D{{Key: "id", Value: int64(1)}}}).
+				SetUpsert(true),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writer := newTestWriter(test.writeMode)
+			writer.embeds.Store(writer.getCollectionKey("orders"), []string{"items"})
+
+			model, err := writer.prepareModel(&DBCommand{Record: test.record, Target: newTestTarget(test.rule)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(model, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, model)
+			}
+		})
+	}
+}
+
+func TestGetShardOfEmbeddedRecords(t *testing.T) {
+
+	target := newTestTarget(`{"collection":"orders","primaryKeys":["item_id"],"shardByPrimaryKey":true,"embed":{"field":"items","foreignKey":"order_id"}}`)
+
+	writer := newTestWriter(WriteModeInsert)
+	writer.assignments = make(map[string]int)
+	writer.shards = make([]*Shard, 8)
+	for i := range writer.shards {
+		writer.shards[i] = &Shard{id: i}
+	}
+
+	// Events of the same parent document are written in order by one shard
+	for orderID := int64(1); orderID <= 16; orderID++ {
+		shard := writer.getShard(&DBCommand{
+			Record: newEmbedRecord(gravity_sdk_types_record.Method_INSERT, "item_id", int64(100), "order_id", orderID),
+			Target: target,
+		})
+
+		for itemID := int64(1); itemID <= 16; itemID++ {
+			for _, method := range []gravity_sdk_types_record.Method{gravity_sdk_types_record.Method_UPDATE, gravity_sdk_types_record.Method_DELETE} {
+				s := writer.getShard(&DBCommand{
+					Record: newEmbedRecord(method, "item_id", itemID, "order_id", orderID),
+					Target: target,
+				})
+
+				if s != shard {
+					t.Fatalf("order %d: %v of item %d went to shard %d instead of %d", orderID, method, itemID, s.id, shard.id)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package writer
+
+import (
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ErrorClass int32
+
+const (
+	ErrorClassRetryable ErrorClass = iota
+	ErrorClassPermanent
+)
+
+var ErrorClassNames = map[ErrorClass]string{
+	ErrorClassRetryable: "retryable",
+	ErrorClassPermanent: "permanent",
+}
+
+// Server error codes which are caused by the document itself, writing it again
+// would always fail.
+var permanentErrorCodes = map[int]bool{
+	2:     true, // BadValue
+	9:     true, // FailedToParse
+	14:    true, // TypeMismatch
+	28:    true, // PathNotViable
+	40:    true, // ConflictingUpdateOperators
+	52:    true, // DollarPrefixedFieldName
+	56:    true, // EmptyFieldName
+	57:    true, // DottedFieldName
+	121:   true, // DocumentValidationFailure
+	10334: true, // BSONObjectTooLarge
+	11000: true, // DuplicateKey
+	11001: true, // DuplicateKeyValue
+	17280: true, // KeyTooLong
+	17419: true, // DocumentTooLarge
+}
+
+// ClassifyWriteError tells whether a write error reported against a specific
+// document is worth retrying. Only errors caused by the document (duplicate
+// key, validation failure, document too large...) are permanent, unknown ones
+// are retried so that records are never dropped because of transient failures.
+func ClassifyWriteError(we mongo.WriteError) ErrorClass {
+
+	if permanentErrorCodes[we.Code] {
+		return ErrorClassPermanent
+	}
+
+	return ErrorClassRetryable
+}
@@ -0,0 +1,40 @@
+package writer
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestClassifyWriteError(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		code     int
+		expected ErrorClass
+	}{
+		{"duplicate key", 11000, ErrorClassPermanent},
+		{"document validation failure", 121, ErrorClassPermanent},
+		{"bad value", 2, ErrorClassPermanent},
+		{"document too large", 10334, ErrorClassPermanent},
+		{"unknown", 0, ErrorClassRetryable},
+		{"unknown code", 99999, ErrorClassRetryable},
+		{"write conflict", 112, ErrorClassRetryable},
+		{"interrupted", 11601, ErrorClassRetryable},
+		{"lock timeout", 24, ErrorClassRetryable},
+		{"rate limited by cosmos db", 16500, ErrorClassRetryable},
+		{"network timeout", 89, ErrorClassRetryable},
+		{"primary stepped down", 189, ErrorClassRetryable},
+		{"not writable primary", 10107, ErrorClassRetryable},
+		{"write concern failed", 64, ErrorClassRetryable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class := ClassifyWriteError(mongo.WriteError{Code: test.code})
+			if class != test.expected {
+				t.Fatalf("expected %s, got %s", ErrorClassNames[test.expected], ErrorClassNames[class])
+			}
+		})
+	}
+}
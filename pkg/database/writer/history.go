@@ -0,0 +1,173 @@
+package writer
+
+import (
+	"context"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistoryID identifies event in history, so the same event delivered again is
+// not appended twice.
+type HistoryID struct {
+	PipelineID uint64 `bson:"pipelineID"`
+	Sequence   uint64 `bson:"sequence"`
+	Collection string `bson:"collection"`
+}
+
+type History struct {
+	ID         *HistoryID             `bson:"_id,omitempty"`
+	Method     string                 `bson:"method"`
+	Key        interface{}            `bson:"key"`
+	Fields     map[string]interface{} `bson:"fields"`
+	PipelineID uint64                 `bson:"pipelineID"`
+	Sequence   uint64                 `bson:"sequence"`
+	WrittenAt  time.Time              `bson:"writtenAt"`
+}
+
+// writeHistory appends events of commands which were written to history
+// collections of targets. It returns false if writer is closing, then commands
+// are left uncompleted to be delivered again.
+func (writer *Writer) writeHistory(shard *Shard, cmds []*DBCommand) bool {
+
+	histories := make(map[string][]mongo.WriteModel)
+	for _, cmd := range cmds {
+
+		// Every original event is kept even if commands were coalesced
+		originals := cmd.merged
+		if len(originals) == 0 {
+			originals = []*DBCommand{cmd}
+		}
+
+		for _, c := range originals {
+
+			if c.Target == nil || c.Target.History == nil {
+				continue
+			}
+
+			history, err := getHistory(c)
+			if err != nil {
+				// Command will be rejected when preparing its write model
+				continue
+			}
+
+			name := c.Target.History.GetCollection(c.Target)
+			histories[name] = append(histories[name], mongo.NewInsertOneModel().SetDocument(history))
+		}
+	}
+
+	for name, models := range histories {
+
+		_, collection, release, err := writer.connections.GetCollection(name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"collection": name,
+			}).Error(err)
+			continue
+		}
+
+		ok := writer.writeHistoryModels(shard, collection, writer.connections.getLabels(name), models)
+		release()
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (writer *Writer) writeHistoryModels(shard *Shard, collection *mongo.Collection, labels collectionLabels, models []mongo.WriteModel) bool {
+
+	opts := options.BulkWrite().SetOrdered(false)
+	name := collection.Name()
+
+	for {
+		metrics.BulkWriteBatchSize.WithLabelValues(labels...).Observe(float64(len(models)))
+
+		startTime := time.Now()
+		_, err := collection.BulkWrite(context.Background(), models, opts)
+		metrics.BulkWriteDuration.WithLabelValues(labels...).Observe(time.Since(startTime).Seconds())
+		if err == nil {
+			return true
+		}
+
+		// Retry the whole batch unless all failures are permanent
+		bwe, ok := err.(mongo.BulkWriteException)
+		if ok && len(bwe.WriteErrors) > 0 && bwe.WriteConcernError == nil {
+
+			pending := make([]mongo.WriteModel, 0)
+			for _, we := range bwe.WriteErrors {
+
+				// Event was appended already
+				if we.Code == duplicateKeyCode {
+					continue
+				}
+
+				log.WithFields(log.Fields{
+					"collection": name,
+					"code":       we.Code,
+				}).Error(we.Message)
+
+				class := ClassifyWriteError(we.WriteError)
+				metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[class])...).Inc()
+				if class == ErrorClassRetryable {
+					pending = append(pending, models[we.Index])
+				}
+			}
+
+			if len(pending) == 0 {
+				return true
+			}
+
+			models = pending
+		} else {
+			log.WithFields(log.Fields{
+				"collection": name,
+			}).Error(err)
+
+			metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[ErrorClassRetryable])...).Inc()
+		}
+
+		metrics.Retries.WithLabelValues(labels...).Inc()
+		shard.setRetrying(true)
+		if !writer.sleep(3 * time.Second) {
+			return false
+		}
+	}
+}
+
+func getHistory(cmd *DBCommand) (*History, error) {
+
+	values, err := convertFields(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &History{
+		Method:     cmd.Record.Method.String(),
+		Fields:     values,
+		PipelineID: cmd.PipelineID,
+		Sequence:   cmd.Sequence,
+		WrittenAt:  time.Now(),
+	}
+
+	// Snapshot records are not in sequence
+	if cmd.Sequence > 0 {
+		history.ID = &HistoryID{
+			PipelineID: cmd.PipelineID,
+			Sequence:   cmd.Sequence,
+			Collection: cmd.Record.Table,
+		}
+	}
+
+	keys := getPrimaryKeys(cmd)
+	if len(keys) > 0 {
+		history.Key = getKeyValue(values, keys)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,55 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+func TestGetHistory(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		sequence uint64
+		expected *HistoryID
+	}{
+		{"event", 12, &HistoryID{PipelineID: 3, Sequence: 12, Collection: "users"}},
+		{"snapshot record", 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			history, err := getHistory(&DBCommand{
+				PipelineID: 3,
+				Sequence:   test.sequence,
+				Record:     newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(history.ID, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, history.ID)
+			}
+
+			if history.Method != "UPDATE" || history.Key != int64(1) {
+				t.Fatalf("unexpected history %+v", history)
+			}
+		})
+	}
+
+	// The same event always has the same ID
+	cmd := &DBCommand{
+		PipelineID: 3,
+		Sequence:   12,
+		Record:     newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1)),
+	}
+
+	first, _ := getHistory(cmd)
+	second, _ := getHistory(cmd)
+	if !reflect.DeepEqual(first.ID, second.ID) {
+		t.Fatalf("expected the same ID, got %v and %v", first.ID, second.ID)
+	}
+}
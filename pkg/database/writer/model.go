@@ -0,0 +1,250 @@
+package writer
+
+import (
+	"fmt"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/converter"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (writer *Writer) prepareModel(cmd *DBCommand) (mongo.WriteModel, error) {
+
+	record := cmd.Record
+	keys := getPrimaryKeys(cmd)
+	useID := writer.usePrimaryKeyAsID(cmd)
+
+	// Convert data to map
+	values, err := convertFields(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Method != gravity_sdk_types_record.Method_DELETE {
+		writer.checkpoints.Stamp(cmd, values)
+	}
+
+	// Filter without value of primary key would match any document
+	switch record.Method {
+	case gravity_sdk_types_record.Method_UPDATE, gravity_sdk_types_record.Method_DELETE:
+		if !hasPrimaryKey(record, keys) {
+			return nil, fmt.Errorf("No primary key to find document of record")
+		}
+	}
+
+	if isEmbedded(cmd) {
+		return prepareEmbedModel(cmd, values, keys)
+	}
+
+	switch record.Method {
+	case gravity_sdk_types_record.Method_DELETE:
+		if softDelete := getSoftDelete(cmd); softDelete != nil {
+			return mongo.NewUpdateOneModel().
+				SetFilter(getKeyFilter(values, keys, useID)).
+				SetUpdate(bson.M{"$set": bson.M{
+					softDelete.GetField():          true,
+					softDelete.GetTimestampField(): primitive.NewDateTimeFromTime(time.Now()),
+				}}), nil
+		}
+
+		return mongo.NewDeleteOneModel().SetFilter(getKeyFilter(values, keys, useID)), nil
+
+	case gravity_sdk_types_record.Method_UPDATE:
+		filter := getKeyFilter(values, keys, useID)
+
+		// Primary key is not going to be changed
+		for _, key := range keys {
+			delete(values, key)
+		}
+
+		update := bson.M{}
+		if len(values) > 0 {
+			update["$set"] = buildUpdate(cmd.Target, values)
+		}
+
+		// Document is alive again
+		if softDelete := getSoftDelete(cmd); softDelete != nil {
+			update["$unset"] = bson.M{
+				softDelete.GetField():          "",
+				softDelete.GetTimestampField(): "",
+			}
+		}
+
+		// Nothing to update other than primary key
+		if len(update) == 0 {
+			return nil, nil
+		}
+
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update), nil
+
+	case gravity_sdk_types_record.Method_INSERT:
+		doc, err := buildDocument(cmd.Target, values, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		if !hasPrimaryKey(record, keys) {
+
+			// Replayed records would be inserted again
+			if writer.writeMode == WriteModeUpsert {
+				return nil, fmt.Errorf("No primary key to upsert record, declare primaryKeys in rules")
+			}
+
+			return mongo.NewInsertOneModel().SetDocument(doc), nil
+		}
+
+		// Parent document might be created by embedded records already
+		if fields := writer.getEmbedFields(record.Table); len(fields) > 0 {
+			return prepareParentModel(cmd, values, keys, useID, fields), nil
+		}
+
+		if useID {
+			doc["_id"] = getKeyValue(values, keys)
+		}
+
+		// Replace existing document by primary key so redelivered records are
+		// harmless. Soft-deleted document might be there as well.
+		if writer.writeMode == WriteModeUpsert || cmd.upsert || getSoftDelete(cmd) != nil {
+			return mongo.NewReplaceOneModel().
+				SetFilter(getKeyFilter(values, keys, useID)).
+				SetReplacement(doc).
+				SetUpsert(true), nil
+		}
+
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	}
+
+	return nil, nil
+}
+
+// convertFields converts values of record to native BSON types with converters of target
+func convertFields(cmd *DBCommand) (map[string]interface{}, error) {
+
+	doc := make(map[string]interface{}, len(cmd.Record.Fields))
+	for _, field := range cmd.Record.Fields {
+
+		fn := converter.Convert
+		if cmd.Target != nil {
+			fn = cmd.Target.GetConverter(field.Name)
+		}
+
+		v, err := fn(field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert field %s: %v", field.Name, err)
+		}
+
+		doc[field.Name] = v
+	}
+
+	return doc, nil
+}
+
+func getSoftDelete(cmd *DBCommand) *rule.SoftDeleteRule {
+
+	if cmd.Target == nil {
+		return nil
+	}
+
+	return cmd.Target.SoftDelete
+}
+
+func (writer *Writer) usePrimaryKeyAsID(cmd *DBCommand) bool {
+
+	if cmd.Target != nil && cmd.Target.PrimaryKeyAsID != nil {
+		return *cmd.Target.PrimaryKeyAsID
+	}
+
+	return writer.primaryKeyAsID
+}
+
+func getPrimaryKeys(cmd *DBCommand) []string {
+
+	// Composite keys declared in rules
+	if cmd.Target != nil && len(cmd.Target.PrimaryKeys) > 0 {
+		return cmd.Target.PrimaryKeys
+	}
+
+	if len(cmd.Record.PrimaryKey) == 0 {
+		return nil
+	}
+
+	return []string{cmd.Record.PrimaryKey}
+}
+
+func isPrimaryKey(keys []string, name string) bool {
+
+	for _, key := range keys {
+		if key == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasPrimaryKey(record *gravity_sdk_types_record.Record, keys []string) bool {
+
+	if len(keys) == 0 {
+		return false
+	}
+
+	for _, key := range keys {
+		field := gravity_sdk_types_record.GetField(record.Fields, key)
+		if field == nil || field.Value == nil || field.Value.Type == gravity_sdk_types_record.DataType_NULL {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getKeyID returns string which identifies document by primary key
+func getKeyID(record *gravity_sdk_types_record.Record, keys []string) string {
+
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		field := gravity_sdk_types_record.GetField(record.Fields, key)
+		if field == nil {
+			values = append(values, nil)
+			continue
+		}
+
+		values = append(values, gravity_sdk_types_record.GetValue(field.Value))
+	}
+
+	return fmt.Sprintf("%v", values)
+}
+
+// getKeyValue returns value of primary key, composite keys are rendered as an
+// embedded document which keeps the order of keys for equality matching.
+func getKeyValue(doc map[string]interface{}, keys []string) interface{} {
+
+	if len(keys) == 1 {
+		return doc[keys[0]]
+	}
+
+	value := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		value = append(value, bson.E{Key: key, Value: doc[key]})
+	}
+
+	return value
+}
+
+func getKeyFilter(doc map[string]interface{}, keys []string, useID bool) interface{} {
+
+	if useID {
+		return bson.D{{Key: "_id", Value: getKeyValue(doc, keys)}}
+	}
+
+	filter := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		filter = append(filter, bson.E{Key: key, Value: doc[key]})
+	}
+
+	return filter
+}
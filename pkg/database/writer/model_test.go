@@ -0,0 +1,265 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newRecord creates record with fields given as name and value pairs
+func newRecord(method gravity_sdk_types_record.Method, primaryKey string, fields ...interface{}) *gravity_sdk_types_record.Record {
+
+	record := &gravity_sdk_types_record.Record{
+		Method:     method,
+		Table:      "users",
+		PrimaryKey: primaryKey,
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		v, err := gravity_sdk_types_record.GetValueFromInterface(fields[i+1])
+		if err != nil {
+			panic(err)
+		}
+
+		record.Fields = append(record.Fields, &gravity_sdk_types_record.Field{
+			Name:  fields[i].(string),
+			Value: v,
+		})
+	}
+
+	return record
+}
+
+func newTestWriter(writeMode string) *Writer {
+	return &Writer{
+		writeMode:   writeMode,
+		checkpoints: &CheckpointTracker{},
+		connections: &ConnectionManager{
+			connectors: map[string]*MongoDBConnector{
+				DefaultConnection: {name: DefaultConnection, dbname: "gravity"},
+			},
+		},
+	}
+}
+
+func TestPrepareModelInsert(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		writeMode string
+		record    *gravity_sdk_types_record.Record
+		expected  interface{}
+		fails     bool
+	}{
+		{
+			name:      "insert mode",
+			writeMode: WriteModeInsert,
+			record:    newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred"),
+			expected:  &mongo.InsertOneModel{},
+		},
+		{
+			name:      "insert mode without primary key",
+			writeMode: WriteModeInsert,
+			record:    newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"),
+			expected:  &mongo.InsertOneModel{},
+		},
+		{
+			name:      "upsert mode",
+			writeMode: WriteModeUpsert,
+			record:    newRecord(gravity_sdk_types_record.Method_INSERT, "id", "id", int64(1), "name", "fred"),
+			expected:  &mongo.ReplaceOneModel{},
+		},
+		{
+			name:      "upsert mode without primary key",
+			writeMode: WriteModeUpsert,
+			record:    newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"),
+			fails:     true,
+		},
+		{
+			name:      "upsert mode with missing primary key field",
+			writeMode: WriteModeUpsert,
+			record:    newRecord(gravity_sdk_types_record.Method_INSERT, "id", "name", "fred"),
+			fails:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writer := newTestWriter(test.writeMode)
+			model, err := writer.prepareModel(&DBCommand{Record: test.record})
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %T", model)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch test.expected.(type) {
+			case *mongo.InsertOneModel:
+				if _, ok := model.(*mongo.InsertOneModel); !ok {
+					t.Fatalf("expected InsertOneModel, got %T", model)
+				}
+			case *mongo.ReplaceOneModel:
+				m, ok := model.(*mongo.ReplaceOneModel)
+				if !ok {
+					t.Fatalf("expected ReplaceOneModel, got %T", model)
+				}
+
+				if m.Upsert == nil || !*m.Upsert {
+					t.Fatal("expected upsert")
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareModelUpdate(t *testing.T) {
+
+	softDelete := newTestTarget(`{"collection":"users","softDelete":{"field":"deleted"}}`)
+
+	tests := []struct {
+		name     string
+		target   *rule.Target
+		record   *gravity_sdk_types_record.Record
+		expected mongo.WriteModel
+	}{
+		{
+			name:   "fields",
+			record: newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1), "name", "fred"),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$set": bson.M{"name": "fred"}}),
+		},
+		{
+			name:   "primary key only",
+			record: newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1)),
+		},
+		{
+			name:   "primary key only with soft delete",
+			target: softDelete,
+			record: newRecord(gravity_sdk_types_record.Method_UPDATE, "id", "id", int64(1)),
+			expected: mongo.NewUpdateOneModel().
+				SetFilter(bson.D{{Key: "id", Value: int64(1)}}).
+				SetUpdate(bson.M{"$unset": bson.M{"deleted": "", "_deletedAt": ""}}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writer := newTestWriter(WriteModeInsert)
+			model, err := writer.prepareModel(&DBCommand{Record: test.record, Target: test.target})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expected == nil {
+				if model != nil {
+					t.Fatalf("expected command to be skipped, got %v", model)
+				}
+
+				return
+			}
+
+			if !reflect.DeepEqual(model, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, model)
+			}
+		})
+	}
+}
+
+func TestPrepareModelWithoutPrimaryKey(t *testing.T) {
+
+	composite := newTestTarget(`{"collection":"users","primaryKeys":["id","region"]}`)
+	softDelete := newTestTarget(`{"collection":"users","softDelete":{"field":"deleted"}}`)
+
+	tests := []struct {
+		name   string
+		target *rule.Target
+		record *gravity_sdk_types_record.Record
+	}{
+		{"update without value of composite key", composite, newRecord(gravity_sdk_types_record.Method_UPDATE, "", "id", int64(1), "name", "fred")},
+		{"delete without value of composite key", composite, newRecord(gravity_sdk_types_record.Method_DELETE, "", "id", int64(1))},
+		{"delete with null key", nil, newRecord(gravity_sdk_types_record.Method_DELETE, "id", "id", nil)},
+		{"delete with key dropped", nil, newRecord(gravity_sdk_types_record.Method_DELETE, "id", "name", "fred")},
+		{"soft delete without key", softDelete, newRecord(gravity_sdk_types_record.Method_DELETE, "id", "name", "fred")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writer := newTestWriter(WriteModeInsert)
+			model, err := writer.prepareModel(&DBCommand{Record: test.record, Target: test.target})
+			if err == nil {
+				t.Fatalf("expected record to be rejected, got %v", model)
+			}
+		})
+	}
+}
+
+func TestGetKeyValue(t *testing.T) {
+
+	doc := map[string]interface{}{
+		"order_id": int64(1),
+		"line_no":  int64(2),
+		"name":     "fred",
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		expected interface{}
+	}{
+		{"single key", []string{"order_id"}, int64(1)},
+		{"composite keys", []string{"order_id", "line_no"}, bson.D{{Key: "order_id", Value: int64(1)}, {Key: "line_no", Value: int64(2)}}},
+		{"order of keys is kept", []string{"line_no", "order_id"}, bson.D{{Key: "line_no", Value: int64(2)}, {Key: "order_id", Value: int64(1)}}},
+		{"missing key", []string{"id"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := getKeyValue(doc, test.keys)
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestGetKeyFilter(t *testing.T) {
+
+	doc := map[string]interface{}{
+		"order_id": int64(1),
+		"line_no":  int64(2),
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		useID    bool
+		expected interface{}
+	}{
+		{"single key", []string{"order_id"}, false, bson.D{{Key: "order_id", Value: int64(1)}}},
+		{"composite keys", []string{"order_id", "line_no"}, false, bson.D{{Key: "order_id", Value: int64(1)}, {Key: "line_no", Value: int64(2)}}},
+		{"single key as _id", []string{"order_id"}, true, bson.D{{Key: "_id", Value: int64(1)}}},
+		{"composite keys as _id", []string{"order_id", "line_no"}, true, bson.D{{Key: "_id", Value: bson.D{{Key: "order_id", Value: int64(1)}, {Key: "line_no", Value: int64(2)}}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := getKeyFilter(doc, test.keys, test.useID)
+			if !reflect.DeepEqual(filter, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, filter)
+			}
+		})
+	}
+}
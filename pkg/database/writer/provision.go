@@ -0,0 +1,272 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IndexInfo struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	Sparse             bool   `bson:"sparse"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+}
+
+// InitializeCollections creates target collections and indexes declared in rules
+func (mdb *MongoDBConnector) InitializeCollections(targets []*rule.Target) error {
+
+	// Client is not closed by rotation while provisioning
+	client, release := mdb.Acquire()
+	defer release()
+
+	// Existing collections of each database
+	databases := make(map[string]map[string]bool)
+
+	for _, target := range targets {
+
+		name, err := rule.ParseCollectionName(target.Collection)
+		if err != nil {
+			return err
+		}
+
+		db := mdb.getDatabase(client, name.Database)
+
+		collections, ok := databases[db.Name()]
+		if !ok {
+			names, err := db.ListCollectionNames(context.Background(), bson.D{})
+			if err != nil {
+				return err
+			}
+
+			collections = make(map[string]bool, len(names))
+			for _, name := range names {
+				collections[name] = true
+			}
+
+			databases[db.Name()] = collections
+		}
+
+		if !collections[name.Collection] {
+			err := mdb.createCollection(db, name.Collection, target)
+			if err != nil {
+				return err
+			}
+
+			collections[name.Collection] = true
+		}
+
+		indexes := mdb.getDeclaredIndexes(target)
+		if len(indexes) == 0 {
+			continue
+		}
+
+		err = mdb.ensureIndexes(db.Collection(name.Collection), indexes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mdb *MongoDBConnector) createCollection(db *mongo.Database, name string, target *rule.Target) error {
+
+	// Collection would be created automatically when writing data
+	if target.CollectionOptions == nil {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"connection": mdb.name,
+		"database":   db.Name(),
+		"collection": name,
+	}).Info("Creating collection")
+
+	return db.CreateCollection(context.Background(), name, target.CollectionOptions.GetCreateOptions())
+}
+
+func (mdb *MongoDBConnector) getDeclaredIndexes(target *rule.Target) []*rule.IndexRule {
+
+	indexes := make([]*rule.IndexRule, 0, len(target.Indexes)+1)
+
+	// Unique index for primary key. It is unnecessary if primary key is _id and
+	// it is not supported by time-series collection.
+	primaryKeyAsID := viper.GetBool("writer.primaryKeyAsID")
+	if target.PrimaryKeyAsID != nil {
+		primaryKeyAsID = *target.PrimaryKeyAsID
+	}
+
+	// Primary key of embedded records is unique in array only
+	if target.Embed != nil {
+		if len(target.PrimaryKeys) > 0 {
+			keys := make([]string, 0, len(target.PrimaryKeys))
+			for _, key := range target.PrimaryKeys {
+				keys = append(keys, target.Embed.Field+"."+key)
+			}
+
+			indexes = append(indexes, &rule.IndexRule{
+				Keys: keys,
+			})
+		}
+
+		// Embedded records create parent document which is not there yet, so
+		// parent key must be unique to detect records pushed already.
+		if parentKey := target.Embed.GetParentKey(); parentKey != "_id" {
+			indexes = append(indexes, &rule.IndexRule{
+				Keys:   []string{parentKey},
+				Unique: true,
+			})
+		}
+
+		return append(indexes, target.Indexes...)
+	}
+
+	isTimeSeries := target.CollectionOptions != nil && target.CollectionOptions.TimeSeries != nil
+	if len(target.PrimaryKeys) > 0 && !primaryKeyAsID && !isTimeSeries {
+		indexes = append(indexes, &rule.IndexRule{
+			Keys:   target.PrimaryKeys,
+			Unique: true,
+		})
+	}
+
+	if target.SoftDelete != nil {
+		if index := target.SoftDelete.GetIndex(); index != nil {
+			indexes = append(indexes, index)
+		}
+	}
+
+	return append(indexes, target.Indexes...)
+}
+
+// EnsurePrimaryKeyIndex creates unique index for primary key which is only known
+// after receiving records. It is performed once for each collection.
+func (mdb *MongoDBConnector) EnsurePrimaryKeyIndex(collection *mongo.Collection, keys []string) {
+
+	cacheKey := fmt.Sprintf("%s.%s/%v", collection.Database().Name(), collection.Name(), keys)
+	if _, loaded := mdb.indexed.LoadOrStore(cacheKey, true); loaded {
+		return
+	}
+
+	err := mdb.ensureIndexes(collection, []*rule.IndexRule{
+		{
+			Keys:   keys,
+			Unique: true,
+		},
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"collection": collection.Name(),
+			"keys":       keys,
+		}).Warnf("Failed to create index for primary key: %v", err)
+	}
+}
+
+func (mdb *MongoDBConnector) ensureIndexes(collection *mongo.Collection, indexes []*rule.IndexRule) error {
+
+	existing, err := mdb.listIndexes(collection)
+	if err != nil {
+		return err
+	}
+
+	models := make([]mongo.IndexModel, 0, len(indexes))
+	declared := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+
+		name := index.GetName()
+		declared[name] = true
+
+		info, ok := existing[name]
+		if !ok {
+			models = append(models, mongo.IndexModel{
+				Keys:    index.GetKeys(),
+				Options: index.GetIndexOptions(),
+			})
+			continue
+		}
+
+		// Index was created already but it is different from rules
+		if !isSameIndex(info, index) {
+			log.WithFields(log.Fields{
+				"collection": collection.Name(),
+				"index":      name,
+			}).Warn("Index drift detected, existing index is different from rules")
+		}
+	}
+
+	for name := range existing {
+		if name == "_id_" || declared[name] {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"collection": collection.Name(),
+			"index":      name,
+		}).Info("Found index which is not declared in rules")
+	}
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"collection": collection.Name(),
+		"count":      len(models),
+	}).Info("Creating indexes")
+
+	_, err = collection.Indexes().CreateMany(context.Background(), models)
+
+	return err
+}
+
+func (mdb *MongoDBConnector) listIndexes(collection *mongo.Collection) (map[string]*IndexInfo, error) {
+
+	cur, err := collection.Indexes().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*IndexInfo
+	err = cur.All(context.Background(), &infos)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]*IndexInfo, len(infos))
+	for _, info := range infos {
+		indexes[info.Name] = info
+	}
+
+	return indexes, nil
+}
+
+func isSameIndex(info *IndexInfo, index *rule.IndexRule) bool {
+
+	if info.Unique != index.Unique || info.Sparse != index.Sparse {
+		return false
+	}
+
+	if !reflect.DeepEqual(info.ExpireAfterSeconds, index.ExpireAfterSeconds) {
+		return false
+	}
+
+	keys := index.GetKeys()
+	if len(info.Key) != len(keys) {
+		return false
+	}
+
+	for i, key := range keys {
+		if info.Key[i].Key != key.Key || fmt.Sprint(info.Key[i].Value) != fmt.Sprint(key.Value) {
+			return false
+		}
+	}
+
+	return true
+}
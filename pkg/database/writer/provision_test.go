@@ -0,0 +1,182 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type indexResult struct {
+	keys   []string
+	unique bool
+	expire int32
+}
+
+func TestGetDeclaredIndexes(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		target         string
+		primaryKeyAsID bool
+		expected       []indexResult
+	}{
+		{
+			name:     "primary key",
+			target:   `{"collection":"users","primaryKeys":["id"]}`,
+			expected: []indexResult{{keys: []string{"id"}, unique: true}},
+		},
+		{
+			name:     "without primary key",
+			target:   `{"collection":"users"}`,
+			expected: []indexResult{},
+		},
+		{
+			name:           "primary key as _id by default",
+			target:         `{"collection":"users","primaryKeys":["id"]}`,
+			primaryKeyAsID: true,
+			expected:       []indexResult{},
+		},
+		{
+			name:     "primary key as _id of target",
+			target:   `{"collection":"users","primaryKeys":["id"],"primaryKeyAsID":true}`,
+			expected: []indexResult{},
+		},
+		{
+			name:           "primary key not as _id of target",
+			target:         `{"collection":"users","primaryKeys":["id"],"primaryKeyAsID":false}`,
+			primaryKeyAsID: true,
+			expected:       []indexResult{{keys: []string{"id"}, unique: true}},
+		},
+		{
+			name:     "time-series collection",
+			target:   `{"collection":"metrics","primaryKeys":["id"],"collectionOptions":{"timeSeries":{"timeField":"ts"}},"indexes":[{"keys":["-ts"]}]}`,
+			expected: []indexResult{{keys: []string{"-ts"}}},
+		},
+		{
+			name:   "soft delete with retention",
+			target: `{"collection":"users","primaryKeys":["id"],"softDelete":{"field":"deleted","retention":86400}}`,
+			expected: []indexResult{
+				{keys: []string{"id"}, unique: true},
+				{keys: []string{"_deletedAt"}, expire: 86400},
+			},
+		},
+		{
+			name:     "soft delete without retention",
+			target:   `{"collection":"users","primaryKeys":["id"],"softDelete":{"field":"deleted"}}`,
+			expected: []indexResult{{keys: []string{"id"}, unique: true}},
+		},
+		{
+			name:   "embedded records",
+			target: `{"collection":"orders","primaryKeys":["id"],"embed":{"field":"items","foreignKey":"orderID","parentKey":"orderID"}}`,
+			expected: []indexResult{
+				{keys: []string{"items.id"}},
+				{keys: []string{"orderID"}, unique: true},
+			},
+		},
+		{
+			name:     "embedded records into parent by _id",
+			target:   `{"collection":"orders","embed":{"field":"items","foreignKey":"orderID"}}`,
+			expected: []indexResult{},
+		},
+		{
+			name:   "declared indexes",
+			target: `{"collection":"users","primaryKeys":["id"],"indexes":[{"keys":["name","-age"],"sparse":true}]}`,
+			expected: []indexResult{
+				{keys: []string{"id"}, unique: true},
+				{keys: []string{"name", "-age"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			viper.Set("writer.primaryKeyAsID", test.primaryKeyAsID)
+			defer viper.Set("writer.primaryKeyAsID", false)
+
+			mdb := &MongoDBConnector{name: DefaultConnection}
+			indexes := mdb.getDeclaredIndexes(newTestTarget(test.target))
+
+			results := make([]indexResult, 0, len(indexes))
+			for _, index := range indexes {
+				result := indexResult{
+					keys:   index.Keys,
+					unique: index.Unique,
+				}
+
+				if index.ExpireAfterSeconds != nil {
+					result.expire = *index.ExpireAfterSeconds
+				}
+
+				results = append(results, result)
+			}
+
+			if !reflect.DeepEqual(results, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, results)
+			}
+		})
+	}
+}
+
+func TestIsSameIndex(t *testing.T) {
+
+	expire := int32(3600)
+	otherExpire := int32(60)
+
+	index := &rule.IndexRule{
+		Keys:               []string{"name", "-age"},
+		Unique:             true,
+		ExpireAfterSeconds: &expire,
+	}
+
+	tests := []struct {
+		name     string
+		info     *IndexInfo
+		expected bool
+	}{
+		{
+			name:     "same",
+			info:     &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}, Unique: true, ExpireAfterSeconds: &expire},
+			expected: true,
+		},
+		{
+			name: "not unique",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}, ExpireAfterSeconds: &expire},
+		},
+		{
+			name: "sparse",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}, Unique: true, Sparse: true, ExpireAfterSeconds: &expire},
+		},
+		{
+			name: "different expiration",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}, Unique: true, ExpireAfterSeconds: &otherExpire},
+		},
+		{
+			name: "without expiration",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)}}, Unique: true},
+		},
+		{
+			name: "different direction",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(1)}}, Unique: true, ExpireAfterSeconds: &expire},
+		},
+		{
+			name: "different order",
+			info: &IndexInfo{Key: bson.D{{Key: "age", Value: int32(-1)}, {Key: "name", Value: int32(1)}}, Unique: true, ExpireAfterSeconds: &expire},
+		},
+		{
+			name: "fewer keys",
+			info: &IndexInfo{Key: bson.D{{Key: "name", Value: int32(1)}}, Unique: true, ExpireAfterSeconds: &expire},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if isSameIndex(test.info, index) != test.expected {
+				t.Fatalf("expected %v", test.expected)
+			}
+		})
+	}
+}
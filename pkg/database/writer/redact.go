@@ -0,0 +1,104 @@
+package writer
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "xxxxx"
+
+// Shorter secrets are only redacted as whole words, or they would garble logs
+// wherever the same characters appear.
+const minSecretLength = 6
+
+var (
+	uriPasswordRegexp = regexp.MustCompile(`(://[^:/@]*:)([^@]*)@`)
+	wordRegexp        = regexp.MustCompile(`^\w$`)
+	secrets           sync.Map
+	redactHookOnce    sync.Once
+)
+
+// RedactURI hides password in connection string
+func RedactURI(uri string) string {
+	return uriPasswordRegexp.ReplaceAllString(uri, "${1}"+redacted+"@")
+}
+
+// RedactSecret makes sure secret never appears in log
+func RedactSecret(secret string) {
+
+	if len(secret) == 0 {
+		return
+	}
+
+	// Long secrets are replaced wherever they appear
+	var pattern *regexp.Regexp
+	if len(secret) < minSecretLength {
+		pattern = getWordPattern(secret)
+	}
+
+	secrets.Store(secret, pattern)
+
+	redactHookOnce.Do(func() {
+		log.AddHook(&redactHook{})
+	})
+}
+
+func redact(s string) string {
+
+	s = RedactURI(s)
+
+	secrets.Range(func(key, value interface{}) bool {
+
+		if pattern := value.(*regexp.Regexp); pattern != nil {
+			s = pattern.ReplaceAllLiteralString(s, redacted)
+			return true
+		}
+
+		s = strings.ReplaceAll(s, key.(string), redacted)
+		return true
+	})
+
+	return s
+}
+
+// getWordPattern matches secret which is not a part of another word. Word
+// boundary only makes sense next to word characters of secret.
+func getWordPattern(secret string) *regexp.Regexp {
+
+	pattern := regexp.QuoteMeta(secret)
+	if wordRegexp.MatchString(secret[:1]) {
+		pattern = `\b` + pattern
+	}
+
+	if wordRegexp.MatchString(secret[len(secret)-1:]) {
+		pattern = pattern + `\b`
+	}
+
+	return regexp.MustCompile(pattern)
+}
+
+// redactHook removes secrets from messages and fields of log entries
+type redactHook struct{}
+
+func (hook *redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *redactHook) Fire(entry *log.Entry) error {
+
+	entry.Message = redact(entry.Message)
+
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = redact(v)
+		case error:
+			entry.Data[key] = redact(v.Error())
+		}
+	}
+
+	return nil
+}
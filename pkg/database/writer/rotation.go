@@ -0,0 +1,150 @@
+package writer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Settings which refer to files of credentials and certificates
+var watchedFiles = []string{
+	"username_file",
+	"password_file",
+	"aws_session_token_file",
+	"ca_file",
+	"cert_file",
+	"key_file",
+	"key_password_file",
+}
+
+// getFingerprint returns hash of files of credentials and certificates
+func (mdb *MongoDBConnector) getFingerprint() (string, error) {
+
+	h := sha256.New()
+	for _, key := range watchedFiles {
+
+		filename := viper.GetString(mdb.prefix + "." + key)
+		if len(filename) == 0 {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+
+		h.Write([]byte(key))
+		h.Write(data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// watch rebuilds client when credentials or certificates were changed
+func (mdb *MongoDBConnector) watch() {
+
+	viper.SetDefault("rotation.enabled", true)
+	viper.SetDefault("rotation.interval", 30)
+
+	if !viper.GetBool("rotation.enabled") {
+		return
+	}
+
+	ticker := time.NewTicker(viper.GetDuration("rotation.interval") * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-mdb.stop:
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := mdb.getFingerprint()
+		if err != nil {
+			// Files might be in the middle of being replaced
+			log.WithFields(log.Fields{
+				"connection": mdb.name,
+			}).Warnf("Failed to check credentials: %v", err)
+			continue
+		}
+
+		if fingerprint == mdb.fingerprint {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"connection": mdb.name,
+		}).Info("Credentials or certificates were changed, rotating client")
+
+		err = mdb.rotate()
+		if err != nil {
+			metrics.Rotations.WithLabelValues(mdb.name, "failure").Inc()
+			log.WithFields(log.Fields{
+				"connection": mdb.name,
+			}).Errorf("Failed to rotate client, keep using the old one: %v", err)
+			continue
+		}
+
+		mdb.fingerprint = fingerprint
+		metrics.Rotations.WithLabelValues(mdb.name, "success").Inc()
+	}
+}
+
+// rotate swaps in a new client, the old client is closed after all writes
+// which are using it finished.
+func (mdb *MongoDBConnector) rotate() error {
+
+	client, err := mdb.newClient()
+	if err != nil {
+		return err
+	}
+
+	mdb.swap(client)
+
+	log.WithFields(log.Fields{
+		"connection": mdb.name,
+	}).Info("Rotated client")
+
+	return nil
+}
+
+// swap replaces current client, the returned channel is closed once the old
+// client was released by all writes and closed.
+func (mdb *MongoDBConnector) swap(client *mongo.Client) <-chan struct{} {
+
+	mdb.mutex.Lock()
+	old := mdb.current
+	mdb.current = &clientRef{
+		client: client,
+	}
+	mdb.mutex.Unlock()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		old.inflight.Wait()
+
+		err := old.client.Disconnect(context.Background())
+		if err != nil {
+			log.WithFields(log.Fields{
+				"connection": mdb.name,
+			}).Error(err)
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"connection": mdb.name,
+		}).Info("Closed the old client")
+	}()
+
+	return closed
+}
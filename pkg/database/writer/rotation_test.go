@@ -0,0 +1,123 @@
+package writer
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestGetFingerprint(t *testing.T) {
+
+	dir := t.TempDir()
+	password := filepath.Join(dir, "password")
+	cert := filepath.Join(dir, "cert.pem")
+
+	writeFile := func(filename string, data string) {
+		err := ioutil.WriteFile(filename, []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(password, "secret")
+	writeFile(cert, "certificate")
+
+	viper.Set("rotation_test.password_file", password)
+	viper.Set("rotation_test.cert_file", cert)
+	defer viper.Set("rotation_test.password_file", "")
+	defer viper.Set("rotation_test.cert_file", "")
+
+	mdb := &MongoDBConnector{name: "test", prefix: "rotation_test"}
+
+	getFingerprint := func() string {
+		fingerprint, err := mdb.getFingerprint()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return fingerprint
+	}
+
+	original := getFingerprint()
+	if getFingerprint() != original {
+		t.Fatal("fingerprint changed without files being changed")
+	}
+
+	writeFile(password, "rotated")
+	rotated := getFingerprint()
+	if rotated == original {
+		t.Fatal("fingerprint did not change after password was changed")
+	}
+
+	writeFile(cert, "renewed")
+	if getFingerprint() == rotated {
+		t.Fatal("fingerprint did not change after certificate was changed")
+	}
+
+	// Files might be in the middle of being replaced
+	viper.Set("rotation_test.password_file", filepath.Join(dir, "missing"))
+	if _, err := mdb.getFingerprint(); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestSwapKeepsOldClientUntilReleased(t *testing.T) {
+
+	newClient := func() *mongo.Client {
+		// Client connects lazily, no server is required
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return client
+	}
+
+	oldClient := newClient()
+	newerClient := newClient()
+	defer newerClient.Disconnect(context.Background())
+
+	mdb := &MongoDBConnector{name: "test"}
+	mdb.current = &clientRef{
+		client: oldClient,
+	}
+
+	client, release := mdb.Acquire()
+	if client != oldClient {
+		t.Fatal("expected old client to be acquired")
+	}
+
+	closed := mdb.swap(newerClient)
+
+	// Writes after rotation are using the new client
+	client, releaseNew := mdb.Acquire()
+	releaseNew()
+	if client != newerClient {
+		t.Fatal("expected new client to be acquired after rotation")
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("old client was closed while it was still in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("old client was not closed after it was released")
+	}
+
+	// Old client was disconnected already
+	if err := oldClient.Disconnect(context.Background()); err != mongo.ErrClientDisconnected {
+		t.Fatalf("expected old client to be disconnected, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package writer
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	buffered_input "github.com/cfsghost/buffered-input"
+	"github.com/spf13/viper"
+)
+
+// Shard owns a buffer and writes its commands independently of other shards.
+// Commands of the same collection (or the same primary key if collection is
+// sharded) always go to the same shard, so they are applied in order.
+type Shard struct {
+	id            int
+	writer        *Writer
+	buffer        *buffered_input.BufferedInput
+	retryingSince int64
+}
+
+// barrier stops all shards until truncation is done
+type barrier struct {
+	arrived sync.WaitGroup
+	release chan struct{}
+}
+
+func NewShard(writer *Writer, id int) *Shard {
+
+	shard := &Shard{
+		id:     id,
+		writer: writer,
+	}
+
+	// Initializing buffered input
+	opts := buffered_input.NewOptions()
+	opts.ChunkSize = viper.GetInt("bufferInput.chunkSize")
+	opts.ChunkCount = 10000
+	opts.Timeout = viper.GetDuration("bufferInput.timeout") * time.Millisecond
+	opts.Handler = shard.chunkHandler
+	shard.buffer = buffered_input.NewBufferedInput(opts)
+
+	return shard
+}
+
+func (shard *Shard) Push(cmd *DBCommand) {
+	shard.buffer.Push(cmd)
+}
+
+// Close waits for chunks pushed before to be handled, then stops buffer
+func (shard *Shard) Close() {
+
+	done := make(chan struct{})
+	shard.buffer.Push(done)
+	shard.buffer.Flush()
+	<-done
+
+	shard.buffer.Close()
+}
+
+func (shard *Shard) wait(b *barrier) {
+	shard.buffer.Push(b)
+	shard.buffer.Flush()
+}
+
+func (shard *Shard) chunkHandler(chunk []interface{}) {
+
+	dbCommands := make([]*DBCommand, 0, len(chunk))
+	for _, request := range chunk {
+
+		switch req := request.(type) {
+		case *DBCommand:
+			dbCommands = append(dbCommands, req)
+		case *barrier:
+
+			// Commands before barrier must be done first
+			if len(dbCommands) > 0 {
+				shard.writer.processData(shard, dbCommands)
+				dbCommands = make([]*DBCommand, 0, len(chunk))
+			}
+
+			req.arrived.Done()
+			<-req.release
+		case chan struct{}:
+
+			// Shard is closing
+			if len(dbCommands) > 0 {
+				shard.writer.processData(shard, dbCommands)
+				dbCommands = make([]*DBCommand, 0, len(chunk))
+			}
+
+			close(req)
+		}
+	}
+
+	if len(dbCommands) > 0 {
+		shard.writer.processData(shard, dbCommands)
+	}
+}
+
+// GetRetryingDuration returns how long shard has been retrying failed writes
+func (shard *Shard) GetRetryingDuration() time.Duration {
+	return getRetryingDuration(&shard.retryingSince)
+}
+
+func (shard *Shard) setRetrying(retrying bool) {
+	setRetrying(&shard.retryingSince, retrying)
+}
+
+func getRetryingDuration(since *int64) time.Duration {
+
+	t := atomic.LoadInt64(since)
+	if t == 0 {
+		return 0
+	}
+
+	return time.Since(time.Unix(0, t))
+}
+
+func setRetrying(since *int64, retrying bool) {
+
+	if !retrying {
+		atomic.StoreInt64(since, 0)
+		return
+	}
+
+	atomic.CompareAndSwapInt64(since, 0, time.Now().UnixNano())
+}
+
+// getShard returns shard for specific command. Collections are assigned to
+// shards in turn, commands of sharded collection are spread by primary key.
+func (writer *Writer) getShard(cmd *DBCommand) *Shard {
+
+	table := cmd.Record.Table
+
+	base, ok := writer.assignments[table]
+	if !ok {
+		base = len(writer.assignments) % len(writer.shards)
+		writer.assignments[table] = base
+	}
+
+	// Sequence of pipeline is fenced per collection, so only one writer is
+	// allowed for each collection in transaction mode.
+	if cmd.Target == nil || !cmd.Target.ShardByPrimaryKey || writer.transaction {
+		return writer.shards[base]
+	}
+
+	// Embedded records follow their parent documents
+	keys := getPrimaryKeys(cmd)
+	if isEmbedded(cmd) {
+		keys = []string{cmd.Target.Embed.ForeignKey}
+	}
+
+	if len(keys) == 0 {
+		return writer.shards[base]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(getKeyID(cmd.Record, keys)))
+
+	return writer.shards[(base+int(h.Sum32()%uint32(len(writer.shards))))%len(writer.shards)]
+}
+
+// dispatchTruncate waits for all shards to finish commands received before,
+// then truncates collection while shards are stopped.
+func (writer *Writer) dispatchTruncate(cmd *DBCommand) {
+
+	b := &barrier{
+		release: make(chan struct{}),
+	}
+
+	b.arrived.Add(len(writer.shards))
+	for _, shard := range writer.shards {
+		shard.wait(b)
+	}
+
+	b.arrived.Wait()
+	writer.processTruncate(cmd)
+	close(b.release)
+}
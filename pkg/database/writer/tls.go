@@ -0,0 +1,199 @@
+package writer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type TLSOptions struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	KeyPassword        string
+	ServerName         string
+	MinVersion         string
+	InsecureSkipVerify bool
+}
+
+func (mdb *MongoDBConnector) getTLSOptions() (*TLSOptions, error) {
+
+	// Password of key is a secret like credentials
+	keyPassword, err := mdb.readSecret("key_password")
+	if err != nil {
+		return nil, err
+	}
+
+	RedactSecret(keyPassword)
+
+	return &TLSOptions{
+		CAFile:             viper.GetString(mdb.prefix + ".ca_file"),
+		CertFile:           viper.GetString(mdb.prefix + ".cert_file"),
+		KeyFile:            viper.GetString(mdb.prefix + ".key_file"),
+		KeyPassword:        keyPassword,
+		ServerName:         viper.GetString(mdb.prefix + ".server_name"),
+		MinVersion:         viper.GetString(mdb.prefix + ".tls_min_version"),
+		InsecureSkipVerify: viper.GetBool(mdb.prefix + ".insecure_skip_verify"),
+	}, nil
+}
+
+// IsEnabled tells whether any option requires custom TLS config
+func (opts *TLSOptions) IsEnabled() bool {
+	return len(opts.CAFile) > 0 ||
+		len(opts.CertFile) > 0 ||
+		len(opts.ServerName) > 0 ||
+		len(opts.MinVersion) > 0 ||
+		opts.InsecureSkipVerify
+}
+
+// LoadTLSConfig returns TLS config for connection, it returns nil if TLS is not configured
+func (mdb *MongoDBConnector) LoadTLSConfig() (*tls.Config, error) {
+
+	opts, err := mdb.getTLSOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.IsEnabled() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if len(opts.MinVersion) > 0 {
+		version, ok := TLSVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Unknown TLS version: %s", opts.MinVersion)
+		}
+
+		tlsConfig.MinVersion = version
+	}
+
+	// Load CA cert
+	if len(opts.CAFile) > 0 {
+		pool, err := LoadCert(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	// Load client certificate for mutual TLS
+	if len(opts.CertFile) > 0 {
+		cert, err := LoadKeyPair(opts.CertFile, opts.KeyFile, opts.KeyPassword)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// LoadCert loads CA bundle
+func LoadCert(caFile string) (*x509.CertPool, error) {
+
+	caCert, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA file: %v", err)
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("No certificate found in CA file: %s", caFile)
+	}
+
+	return caCertPool, nil
+}
+
+// LoadKeyPair loads client certificate and private key which might be
+// encrypted. Key is read from certificate file if key file is not specified.
+func LoadKeyPair(certFile string, keyFile string, password string) (tls.Certificate, error) {
+
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Failed to read certificate file: %v", err)
+	}
+
+	keyPEM := certPEM
+	if len(keyFile) > 0 {
+		keyPEM, err = ioutil.ReadFile(keyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("Failed to read key file: %v", err)
+		}
+	}
+
+	if len(password) > 0 {
+		keyPEM, err = decryptKey(keyPEM, password)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Failed to load client certificate %s: %v", certFile, err)
+	}
+
+	return cert, nil
+}
+
+// decryptKey decrypts private key in legacy encrypted PEM format (RFC 1423),
+// PKCS#8 encrypted keys are not supported by standard library. Key which is
+// not encrypted is returned as it is.
+func decryptKey(data []byte, password string) ([]byte, error) {
+
+	found := false
+	rest := data
+	for {
+		block, next := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		rest = next
+
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		found = true
+
+		if !x509.IsEncryptedPEMBlock(block) {
+			continue
+		}
+
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decrypt private key: %v", err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  block.Type,
+			Bytes: der,
+		}), nil
+	}
+
+	if !found {
+		return nil, fmt.Errorf("No private key found")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,189 @@
+package writer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// newTestKeyPair returns self-signed certificate and private key in PEM format,
+// key is encrypted if password is given.
+func newTestKeyPair(t *testing.T, password string) ([]byte, []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gravity"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBlock := &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+	if len(password) > 0 {
+		keyBlock, err = x509.EncryptPEMBlock(rand.Reader, keyBlock.Type, keyDER, []byte(password), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(keyBlock)
+}
+
+func writeTestFile(t *testing.T, dir string, name string, data []byte) string {
+
+	filename := filepath.Join(dir, name)
+	err := ioutil.WriteFile(filename, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func TestLoadKeyPair(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		encryptWith string
+		password    string
+		combined    bool
+		fails       bool
+	}{
+		{name: "plain key"},
+		{name: "plain key with password", password: "secret"},
+		{name: "encrypted key", encryptWith: "secret", password: "secret"},
+		{name: "encrypted key with wrong password", encryptWith: "secret", password: "wrong", fails: true},
+		{name: "encrypted key without password", encryptWith: "secret", fails: true},
+		{name: "combined file", combined: true},
+		{name: "combined file with password", password: "secret", combined: true},
+		{name: "combined file with encrypted key", encryptWith: "secret", password: "secret", combined: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			dir := t.TempDir()
+			certPEM, keyPEM := newTestKeyPair(t, test.encryptWith)
+
+			var certFile, keyFile string
+			if test.combined {
+				certFile = writeTestFile(t, dir, "client.pem", append(certPEM, keyPEM...))
+			} else {
+				certFile = writeTestFile(t, dir, "client.crt", certPEM)
+				keyFile = writeTestFile(t, dir, "client.key", keyPEM)
+			}
+
+			cert, err := LoadKeyPair(certFile, keyFile, test.password)
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cert.Certificate) != 1 || cert.PrivateKey == nil {
+				t.Fatal("certificate or private key is missing")
+			}
+		})
+	}
+}
+
+func TestDecryptKey(t *testing.T) {
+
+	certPEM, keyPEM := newTestKeyPair(t, "")
+	_, encryptedPEM := newTestKeyPair(t, "secret")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		unchanged bool
+		fails     bool
+	}{
+		{name: "plain key", data: keyPEM, unchanged: true},
+		{name: "certificate and plain key", data: append(append([]byte{}, certPEM...), keyPEM...), unchanged: true},
+		{name: "encrypted key", data: encryptedPEM},
+		{name: "certificate only", data: certPEM, fails: true},
+		{name: "not PEM", data: []byte("secret"), fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			data, err := decryptKey(test.data, "secret")
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.unchanged && string(data) != string(test.data) {
+				t.Fatal("plain key should be returned as it is")
+			}
+
+			block, _ := pem.Decode(data)
+			if block == nil || x509.IsEncryptedPEMBlock(block) {
+				t.Fatal("expected decrypted key")
+			}
+		})
+	}
+}
+
+func TestGetTLSOptionsKeyPassword(t *testing.T) {
+
+	filename := filepath.Join(t.TempDir(), "key_password")
+	err := ioutil.WriteFile(filename, []byte("k3y-pa55phrase\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("tls_test.key_password_file", filename)
+
+	mdb := &MongoDBConnector{name: "test", prefix: "tls_test"}
+	opts, err := mdb.getTLSOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.KeyPassword != "k3y-pa55phrase" {
+		t.Fatalf("expected password from file, got %q", opts.KeyPassword)
+	}
+
+	if message := redact("decrypting with k3y-pa55phrase"); message != "decrypting with xxxxx" {
+		t.Fatalf("expected password to be redacted, got %q", message)
+	}
+}
@@ -0,0 +1,262 @@
+package writer
+
+import (
+	"context"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+type FenceID struct {
+	PipelineID uint64 `bson:"pipeline"`
+	Collection string `bson:"collection"`
+}
+
+// Fence is the last sequence of pipeline applied to collection
+type Fence struct {
+	ID       FenceID `bson:"_id"`
+	Sequence uint64  `bson:"sequence"`
+}
+
+type transactionResult struct {
+	applied []*DBCommand
+	skipped []*DBCommand
+	// models of commands applied
+	models []mongo.WriteModel
+	// the last sequences of pipelines applied
+	sequences map[uint64]uint64
+}
+
+func (writer *Writer) initTransaction() {
+
+	viper.SetDefault("transaction.enabled", false)
+	viper.SetDefault("transaction.collection", "_gravity_sequences")
+
+	writer.transaction = viper.GetBool("transaction.enabled")
+	writer.fenceCollection = viper.GetString("transaction.collection")
+}
+
+// writeTransaction writes commands together with sequences of pipelines in a
+// transaction. Commands which are not newer than sequence applied are skipped,
+// so redelivered events never roll documents back.
+func (writer *Writer) writeTransaction(shard *Shard, collection *mongo.Collection, cmds []*DBCommand, models []mongo.WriteModel) []*DBCommand {
+
+	name := collection.Name()
+	labels := writer.connections.getLabels(cmds[0].Record.Table)
+	written := make([]*DBCommand, 0, len(cmds))
+
+	for len(cmds) > 0 {
+
+		result, err := writer.commitTransaction(collection, labels, cmds, models)
+		if err == nil {
+			shard.setRetrying(false)
+
+			if len(result.skipped) > 0 {
+				metrics.CommandsSkipped.WithLabelValues(labels...).Add(float64(len(result.skipped)))
+			}
+
+			// Skipped commands are kept in history again in case it was
+			// interrupted after they were applied
+			written = append(written, result.skipped...)
+
+			return append(written, result.applied...)
+		}
+
+		// Nothing was written because transaction was aborted. Poison record
+		// is moved to dead letter queue then the rest are written again.
+		bwe, ok := err.(mongo.BulkWriteException)
+		if ok && len(bwe.WriteErrors) > 0 && result != nil {
+
+			we := bwe.WriteErrors[0]
+			failed := result.applied[we.Index]
+
+			// Embedded record was pushed before
+			pushed, err := isPushed(collection, failed, we.WriteError)
+			if err != nil {
+				log.Error(err)
+			} else if pushed {
+				written = append(written, failed)
+				cmds, models = removeCommand(cmds, models, failed)
+				continue
+			}
+
+			class := ClassifyWriteError(we.WriteError)
+			metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[class])...).Inc()
+
+			if class == ErrorClassPermanent {
+
+				err := writer.deadLetter.Push(failed, we.WriteError)
+				if err == nil {
+					writer.complete(failed)
+					cmds, models = removeCommand(cmds, models, failed)
+					continue
+				}
+
+				log.Error(err)
+			}
+		} else {
+			metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[ErrorClassRetryable])...).Inc()
+		}
+
+		log.WithFields(log.Fields{
+			"collection": name,
+		}).Error(err)
+
+		metrics.Retries.WithLabelValues(labels...).Inc()
+		shard.setRetrying(true)
+		if !writer.sleep(3 * time.Second) {
+			return written
+		}
+	}
+
+	return written
+}
+
+func (writer *Writer) commitTransaction(collection *mongo.Collection, labels collectionLabels, cmds []*DBCommand, models []mongo.WriteModel) (*transactionResult, error) {
+
+	ctx := context.Background()
+	name := collection.Name()
+	fences := collection.Database().Collection(writer.fenceCollection)
+
+	session, err := collection.Database().Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	opts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+
+	var result *transactionResult
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+
+		applied, err := getFences(sc, fences, name, cmds)
+		if err != nil {
+			return nil, err
+		}
+
+		// Transaction might be performed again
+		result = applyFences(cmds, models, applied)
+
+		if len(result.models) > 0 {
+			metrics.BulkWriteBatchSize.WithLabelValues(labels...).Observe(float64(len(result.models)))
+
+			startTime := time.Now()
+			_, err = collection.BulkWrite(sc, result.models, options.BulkWrite().SetOrdered(true))
+			metrics.BulkWriteDuration.WithLabelValues(labels...).Observe(time.Since(startTime).Seconds())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Update sequences applied
+		for pipelineID, seq := range result.sequences {
+			_, err := fences.UpdateOne(sc,
+				bson.M{"_id": FenceID{PipelineID: pipelineID, Collection: name}},
+				bson.M{"$max": bson.M{"sequence": seq}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}, opts)
+
+	return result, err
+}
+
+// applyFences skips commands which are not newer than sequences of pipelines
+// applied, and returns the last sequences of pipelines going to be applied.
+func applyFences(cmds []*DBCommand, models []mongo.WriteModel, applied map[uint64]uint64) *transactionResult {
+
+	result := &transactionResult{
+		models:    make([]mongo.WriteModel, 0, len(models)),
+		sequences: make(map[uint64]uint64),
+	}
+
+	for i, cmd := range cmds {
+
+		// Snapshot records are not in sequence
+		if cmd.Sequence == 0 {
+			result.applied = append(result.applied, cmd)
+			result.models = append(result.models, models[i])
+			continue
+		}
+
+		if cmd.Sequence <= applied[cmd.PipelineID] {
+			result.skipped = append(result.skipped, cmd)
+			continue
+		}
+
+		result.applied = append(result.applied, cmd)
+		result.models = append(result.models, models[i])
+
+		if cmd.Sequence > result.sequences[cmd.PipelineID] {
+			result.sequences[cmd.PipelineID] = cmd.Sequence
+		}
+	}
+
+	return result
+}
+
+// getFences returns the last sequences of pipelines applied to collection
+func getFences(ctx context.Context, fences *mongo.Collection, name string, cmds []*DBCommand) (map[uint64]uint64, error) {
+
+	ids := make(bson.A, 0)
+	seen := make(map[uint64]bool)
+	for _, cmd := range cmds {
+		if cmd.Sequence == 0 || seen[cmd.PipelineID] {
+			continue
+		}
+
+		seen[cmd.PipelineID] = true
+		ids = append(ids, FenceID{PipelineID: cmd.PipelineID, Collection: name})
+	}
+
+	applied := make(map[uint64]uint64, len(ids))
+	if len(ids) == 0 {
+		return applied, nil
+	}
+
+	cur, err := fences.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Fence
+	err = cur.All(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fence := range results {
+		applied[fence.ID.PipelineID] = fence.Sequence
+	}
+
+	return applied, nil
+}
+
+func removeCommand(cmds []*DBCommand, models []mongo.WriteModel, target *DBCommand) ([]*DBCommand, []mongo.WriteModel) {
+
+	for i, cmd := range cmds {
+		if cmd != target {
+			continue
+		}
+
+		cmds = append(cmds[:i:i], cmds[i+1:]...)
+		models = append(models[:i:i], models[i+1:]...)
+		break
+	}
+
+	return cmds, models
+}
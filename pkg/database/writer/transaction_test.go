@@ -0,0 +1,134 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestApplyFences(t *testing.T) {
+
+	type position struct {
+		pipelineID uint64
+		sequence   uint64
+	}
+
+	tests := []struct {
+		name      string
+		commands  []position
+		applied   map[uint64]uint64
+		expected  []int
+		skipped   []int
+		sequences map[uint64]uint64
+	}{
+		{
+			name:      "nothing applied",
+			commands:  []position{{1, 10}, {2, 5}},
+			applied:   map[uint64]uint64{},
+			expected:  []int{0, 1},
+			sequences: map[uint64]uint64{1: 10, 2: 5},
+		},
+		{
+			name:      "stale, equal and newer sequences of two pipelines",
+			commands:  []position{{1, 9}, {2, 20}, {1, 10}, {2, 21}, {1, 11}, {2, 19}},
+			applied:   map[uint64]uint64{1: 10, 2: 20},
+			expected:  []int{3, 4},
+			skipped:   []int{0, 1, 2, 5},
+			sequences: map[uint64]uint64{1: 11, 2: 21},
+		},
+		{
+			name:      "the last sequence of pipeline is the highest",
+			commands:  []position{{1, 12}, {1, 11}},
+			applied:   map[uint64]uint64{1: 10},
+			expected:  []int{0, 1},
+			sequences: map[uint64]uint64{1: 12},
+		},
+		{
+			name:      "snapshot records",
+			commands:  []position{{1, 0}, {1, 5}},
+			applied:   map[uint64]uint64{1: 10},
+			expected:  []int{0},
+			skipped:   []int{1},
+			sequences: map[uint64]uint64{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			cmds := make([]*DBCommand, 0, len(test.commands))
+			models := make([]mongo.WriteModel, 0, len(test.commands))
+			for _, p := range test.commands {
+				cmds = append(cmds, &DBCommand{PipelineID: p.pipelineID, Sequence: p.sequence})
+				models = append(models, mongo.NewInsertOneModel())
+			}
+
+			result := applyFences(cmds, models, test.applied)
+
+			if len(result.applied) != len(test.expected) || len(result.models) != len(test.expected) {
+				t.Fatalf("expected %d commands applied, got %d with %d models", len(test.expected), len(result.applied), len(result.models))
+			}
+
+			for i, index := range test.expected {
+				if result.applied[i] != cmds[index] || result.models[i] != models[index] {
+					t.Fatalf("expected command %d to be applied at %d", index, i)
+				}
+			}
+
+			if len(result.skipped) != len(test.skipped) {
+				t.Fatalf("expected %d commands skipped, got %d", len(test.skipped), len(result.skipped))
+			}
+
+			for i, index := range test.skipped {
+				if result.skipped[i] != cmds[index] {
+					t.Fatalf("expected command %d to be skipped at %d", index, i)
+				}
+			}
+
+			if !reflect.DeepEqual(result.sequences, test.sequences) {
+				t.Fatalf("expected sequences %v, got %v", test.sequences, result.sequences)
+			}
+		})
+	}
+}
+
+func TestRemoveCommand(t *testing.T) {
+
+	cmds := []*DBCommand{{Sequence: 1}, {Sequence: 2}, {Sequence: 3}}
+	models := []mongo.WriteModel{mongo.NewInsertOneModel(), mongo.NewInsertOneModel(), mongo.NewInsertOneModel()}
+
+	tests := []struct {
+		name     string
+		target   *DBCommand
+		expected []int
+	}{
+		{"first", cmds[0], []int{1, 2}},
+		{"middle", cmds[1], []int{0, 2}},
+		{"last", cmds[2], []int{0, 1}},
+		{"not found", &DBCommand{Sequence: 2}, []int{0, 1, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			restCmds, restModels := removeCommand(cmds, models, test.target)
+			if len(restCmds) != len(test.expected) || len(restModels) != len(test.expected) {
+				t.Fatalf("expected %d commands left, got %d with %d models", len(test.expected), len(restCmds), len(restModels))
+			}
+
+			for i, index := range test.expected {
+				if restCmds[i] != cmds[index] || restModels[i] != models[index] {
+					t.Fatalf("expected command %d at %d", index, i)
+				}
+			}
+		})
+	}
+
+	// Original slices are never modified
+	for i, cmd := range cmds {
+		if cmd.Sequence != uint64(i+1) {
+			t.Fatalf("original commands were modified")
+		}
+	}
+}
@@ -1,6 +1,219 @@
 package writer
 
-func (writer *Writer) Truncate(table string) error {
+import (
+	"context"
+	"time"
 
-	return nil
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	TruncateModeDelete = "delete"
+	TruncateModeDrop   = "drop"
+)
+
+// Truncate removes all documents of specific collection after all commands
+// received before were written.
+func (writer *Writer) Truncate(target *rule.Target) error {
+
+	cmd := &DBCommand{
+		Record: &gravity_sdk_types_record.Record{
+			Method: gravity_sdk_types_record.Method_TRUNCATE,
+			Table:  target.Collection,
+		},
+		Target: target,
+		done:   make(chan error, 1),
+	}
+
+	select {
+	case writer.commands <- cmd:
+	case <-writer.stop:
+		return ErrWriterClosed
+	}
+
+	select {
+	case err := <-cmd.done:
+		return err
+	case <-writer.stop:
+	}
+
+	// Command might have been handled before dispatcher exited
+	writer.wg.Wait()
+
+	select {
+	case err := <-cmd.done:
+		return err
+	default:
+		return ErrWriterClosed
+	}
+}
+
+func (writer *Writer) TruncateRecord(cmd *DBCommand) (bool, error) {
+
+	err := writer.push(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (writer *Writer) processTruncate(cmd *DBCommand) {
+
+	table := cmd.Record.Table
+
+	for {
+		err := writer.truncate(cmd)
+		if err == nil {
+			writer.setRetrying(false)
+			break
+		}
+
+		// Report to caller instead of retrying
+		if cmd.done != nil {
+			cmd.done <- err
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"collection": table,
+		}).Error(err)
+
+		writer.setRetrying(true)
+		if !writer.sleep(3 * time.Second) {
+			return
+		}
+	}
+
+	if cmd.done != nil {
+		cmd.done <- nil
+		return
+	}
+
+	writer.complete(cmd)
+}
+
+func (writer *Writer) truncate(cmd *DBCommand) error {
+
+	table := cmd.Record.Table
+
+	_, collection, release, err := writer.connections.GetCollection(table)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Only clear embedded records in parent documents
+	if isEmbedded(cmd) {
+		log.WithFields(log.Fields{
+			"collection": table,
+			"field":      cmd.Target.Embed.Field,
+		}).Warn("Truncating embedded records")
+
+		_, err := collection.UpdateMany(context.Background(), bson.D{}, bson.M{
+			"$set": bson.M{cmd.Target.Embed.Field: bson.A{}},
+		})
+
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"collection": table,
+		"mode":       writer.truncateMode,
+	}).Warn("Truncating collection")
+
+	if writer.truncateMode == TruncateModeDrop {
+		return writer.recreateCollection(collection.Database(), collection.Name())
+	}
+
+	_, err = collection.DeleteMany(context.Background(), bson.D{})
+
+	return err
+}
+
+// recreateCollection drops collection and creates it again with the same options and indexes
+func (writer *Writer) recreateCollection(db *mongo.Database, table string) error {
+
+	ctx := context.Background()
+
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: table}})
+	if err != nil {
+		return err
+	}
+
+	// Nothing to truncate
+	if len(specs) == 0 {
+		return nil
+	}
+
+	collection := db.Collection(table)
+
+	// Getting indexes
+	cur, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var indexes []bson.D
+	err = cur.All(ctx, &indexes)
+	if err != nil {
+		return err
+	}
+
+	// Getting options of collection
+	var opts bson.D
+	if len(specs[0].Options) > 0 {
+		err = bson.Unmarshal(specs[0].Options, &opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = collection.Drop(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create collection
+	createCmd := append(bson.D{{Key: "create", Value: table}}, opts...)
+	err = db.RunCommand(ctx, createCmd).Err()
+	if err != nil {
+		return err
+	}
+
+	// Create indexes
+	specsToCreate := make(bson.A, 0, len(indexes))
+	for _, index := range indexes {
+
+		spec := make(bson.D, 0, len(index))
+		skip := false
+		for _, e := range index {
+			switch e.Key {
+			case "name":
+				skip = e.Value == "_id_"
+			case "ns":
+				// Not allowed for creating index
+				continue
+			}
+
+			spec = append(spec, e)
+		}
+
+		if !skip {
+			specsToCreate = append(specsToCreate, spec)
+		}
+	}
+
+	if len(specsToCreate) == 0 {
+		return nil
+	}
+
+	return db.RunCommand(ctx, bson.D{
+		{Key: "createIndexes", Value: table},
+		{Key: "indexes", Value: specsToCreate},
+	}).Err()
 }
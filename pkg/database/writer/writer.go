@@ -2,16 +2,20 @@ package writer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
 	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/database"
-
-	buffered_input "github.com/cfsghost/buffered-input"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -31,6 +35,13 @@ type DatabaseInfo struct {
 	DbName   string `json:"dbname"`
 }
 
+const (
+	WriteModeInsert = "insert"
+	WriteModeUpsert = "upsert"
+)
+
+var ErrWriterClosed = errors.New("Writer was closed")
+
 type CollectionRecord struct {
 	models []mongo.WriteModel
 	cmds   []*DBCommand
@@ -38,49 +49,218 @@ type CollectionRecord struct {
 
 type Writer struct {
 	dbInfo            *DatabaseInfo
-	connector         *MongoDBConnector
+	connections       *ConnectionManager
 	commands          chan *DBCommand
 	completionHandler database.CompletionHandler
-	buffer            *buffered_input.BufferedInput
+	shards            []*Shard
+	assignments       map[string]int
+	deadLetter        *DeadLetterQueue
+	checkpoints       *CheckpointTracker
+	writeMode         string
+	ordered           bool
+	primaryKeyAsID    bool
+	ensureIndex       bool
+	truncateMode      string
+	coalesce          bool
+	transaction       bool
+	fenceCollection   string
+	pending           int64
+	retryingSince     int64
+	stop              chan struct{}
+	wg                sync.WaitGroup
+
+	// Array fields which records are embedded into, by parent collection
+	embeds sync.Map
 }
 
 func NewWriter() *Writer {
 
+	viper.SetDefault("writer.writeMode", WriteModeInsert)
+	viper.SetDefault("writer.ordered", true)
+	viper.SetDefault("writer.primaryKeyAsID", false)
+	viper.SetDefault("writer.ensurePrimaryKeyIndex", true)
+	viper.SetDefault("writer.truncateMode", TruncateModeDelete)
+	viper.SetDefault("writer.workerCount", 1)
+	viper.SetDefault("writer.coalesce", false)
+
+	connections := NewConnectionManager()
+
 	writer := &Writer{
 		dbInfo:            &DatabaseInfo{},
-		connector:         NewMongoDBConnector(),
+		connections:       connections,
 		commands:          make(chan *DBCommand, 2048),
+		stop:              make(chan struct{}),
 		completionHandler: func(database.DBCommand) {},
+		deadLetter:        NewDeadLetterQueue(connections.GetDefault()),
+		checkpoints:       NewCheckpointTracker(connections.GetDefault()),
+		writeMode:         viper.GetString("writer.writeMode"),
+		ordered:           viper.GetBool("writer.ordered"),
+		primaryKeyAsID:    viper.GetBool("writer.primaryKeyAsID"),
+		ensureIndex:       viper.GetBool("writer.ensurePrimaryKeyIndex"),
+		truncateMode:      viper.GetString("writer.truncateMode"),
+		coalesce:          viper.GetBool("writer.coalesce"),
+		assignments:       make(map[string]int),
+	}
+
+	writer.initTransaction()
+
+	// Initializing shards
+	workerCount := viper.GetInt("writer.workerCount")
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	writer.shards = make([]*Shard, workerCount)
+	for i := range writer.shards {
+		writer.shards[i] = NewShard(writer, i)
 	}
-	// Initializing buffered input
-	opts := buffered_input.NewOptions()
-	opts.ChunkSize = viper.GetInt("bufferInput.chunkSize")
-	opts.ChunkCount = 10000
-	opts.Timeout = viper.GetDuration("bufferInput.timeout") * time.Millisecond
-	opts.Handler = writer.chunkHandler
-	writer.buffer = buffered_input.NewBufferedInput(opts)
 
 	return writer
 }
 
 func (writer *Writer) Init() error {
 
+	switch writer.writeMode {
+	case WriteModeInsert, WriteModeUpsert:
+	default:
+		return fmt.Errorf("Unknown write mode: %s", writer.writeMode)
+	}
+
+	switch writer.truncateMode {
+	case TruncateModeDelete, TruncateModeDrop:
+	default:
+		return fmt.Errorf("Unknown truncate mode: %s", writer.truncateMode)
+	}
+
+	log.WithFields(log.Fields{
+		"mode":        writer.writeMode,
+		"workers":     len(writer.shards),
+		"transaction": writer.transaction,
+	}).Info("Initializing writer")
+
 	// Connect to database
-	err := writer.connector.Connect()
+	err := writer.connections.Connect()
+	if err != nil {
+		return err
+	}
+
+	// Load rules
+	ruleConfig, err := rule.LoadFile(viper.GetString("rules.subscription"))
+	if err != nil {
+		return err
+	}
+
+	targets := make([]*rule.Target, 0)
+	for _, ts := range ruleConfig.Subscriptions {
+		targets = append(targets, ts...)
+	}
+
+	err = writer.PrepareTargets(targets)
 	if err != nil {
 		return err
 	}
 
+	err = writer.deadLetter.Init()
+	if err != nil {
+		return err
+	}
+
+	err = writer.checkpoints.Init()
+	if err != nil {
+		return err
+	}
+
+	metrics.RegisterGauge("writer_queue_depth", "Number of commands waiting in writer queue", func() float64 {
+		return float64(len(writer.commands))
+	})
+
+	metrics.RegisterGauge("writer_pending_commands", "Number of commands which are not written yet", func() float64 {
+		return float64(writer.GetPendingCount())
+	})
+
+	writer.wg.Add(1)
 	go writer.run()
 
 	return nil
 }
 
+// PrepareTargets creates collections and indexes of targets added to rules
+func (writer *Writer) PrepareTargets(targets []*rule.Target) error {
+
+	err := writer.validateTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if target.Embed == nil {
+			continue
+		}
+
+		key := writer.getCollectionKey(target.Collection)
+
+		fields := []string{target.Embed.Field}
+		if v, ok := writer.embeds.Load(key); ok {
+			for _, field := range v.([]string) {
+				if field != target.Embed.Field {
+					fields = append(fields, field)
+				}
+			}
+		}
+
+		writer.embeds.Store(key, fields)
+	}
+
+	return writer.connections.InitializeTargets(targets)
+}
+
+// validateTargets checks targets against write mode. Snapshot records carry
+// no primary key, so they can only be upserted by primaryKeys declared in rules.
+func (writer *Writer) validateTargets(targets []*rule.Target) error {
+
+	if writer.writeMode != WriteModeUpsert {
+		return nil
+	}
+
+	for _, target := range targets {
+		if len(target.PrimaryKeys) == 0 {
+			return fmt.Errorf("No primaryKeys declared for target %s, it is required in upsert mode", target.Collection)
+		}
+	}
+
+	return nil
+}
+
+// getEmbedFields returns array fields of collection which records are embedded into
+func (writer *Writer) getEmbedFields(table string) []string {
+
+	v, ok := writer.embeds.Load(writer.getCollectionKey(table))
+	if !ok {
+		return nil
+	}
+
+	return v.([]string)
+}
+
+func (writer *Writer) getCollectionKey(table string) string {
+	return strings.Join(writer.connections.getLabels(table), "/")
+}
+
 func (writer *Writer) run() {
+
+	defer writer.wg.Done()
+
 	for {
 		select {
+		case <-writer.stop:
+			return
 		case cmd := <-writer.commands:
-			writer.buffer.Push(cmd)
+			if cmd.Record.Method == gravity_sdk_types_record.Method_TRUNCATE {
+				writer.dispatchTruncate(cmd)
+				continue
+			}
+
+			writer.getShard(cmd).Push(cmd)
 		}
 	}
 }
@@ -89,66 +269,173 @@ func (writer *Writer) SetCompletionHandler(fn database.CompletionHandler) {
 	writer.completionHandler = fn
 }
 
-func (writer *Writer) chunkHandler(chunk []interface{}) {
+func (writer *Writer) push(cmd *DBCommand) error {
+
+	select {
+	case <-writer.stop:
+		return ErrWriterClosed
+	default:
+	}
+
+	metrics.CommandsBuffered.WithLabelValues(writer.connections.getLabels(cmd.Record.Table)...).Inc()
+	atomic.AddInt64(&writer.pending, 1)
+	writer.checkpoints.Add(cmd)
 
-	dbCommands := make([]*DBCommand, 0, len(chunk))
-	for _, request := range chunk {
-		req := request.(*DBCommand)
-		dbCommands = append(dbCommands, req)
+	select {
+	case writer.commands <- cmd:
+		return nil
+	case <-writer.stop:
+		// Command is not going to be written, so it stays pending in checkpoint
+		atomic.AddInt64(&writer.pending, -1)
+		return ErrWriterClosed
 	}
-	writer.processData(dbCommands)
 }
 
-func (writer *Writer) processData(dbCommands []*DBCommand) {
+func (writer *Writer) complete(cmd *DBCommand) {
 
-	// Getting collection
-	mdb := writer.connector.GetClient().Database(viper.GetString("mongodb.dbname"))
+	if len(cmd.merged) > 0 {
+		for _, c := range cmd.merged {
+			writer.complete(c)
+		}
 
-	colls := make(map[string]*CollectionRecord, 0)
-	//var models []mongo.WriteModel
-	//var cmds []*DBCommand
-	for _, cmd := range dbCommands {
+		return
+	}
 
-		record := cmd.Record
-		// Convert data to map
-		doc := make(map[string]interface{}, len(record.Fields))
+	writer.checkpoints.Done(cmd)
+	writer.completionHandler(cmd)
+	atomic.AddInt64(&writer.pending, -1)
+}
 
-		var model mongo.WriteModel
+// reject moves command which can never be written to dead letter queue
+func (writer *Writer) reject(cmd *DBCommand, err error) {
 
-		switch record.Method {
-		case gravity_sdk_types_record.Method_DELETE:
-			var value interface{}
-			for _, field := range record.Fields {
-				// Getting primary key
-				if record.PrimaryKey == field.Name {
-					value = gravity_sdk_types_record.GetValue(field.Value)
-					continue
-				}
-			}
-			model = mongo.NewDeleteOneModel().SetFilter(bson.M{record.PrimaryKey: value})
-
-		case gravity_sdk_types_record.Method_UPDATE:
-			var value interface{}
-			for _, field := range record.Fields {
-				// Getting primary key
-				if record.PrimaryKey == field.Name {
-					value = gravity_sdk_types_record.GetValue(field.Value)
-					continue
-				}
+	labels := writer.connections.getLabels(cmd.Record.Table)
+	metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[ErrorClassPermanent])...).Inc()
 
-				// Getting updated fields
-				doc[field.Name] = gravity_sdk_types_record.GetValue(field.Value)
-			}
+	err = writer.deadLetter.Push(cmd, mongo.WriteError{
+		Index:   -1,
+		Message: err.Error(),
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	writer.complete(cmd)
+}
+
+// GetPendingCount returns number of commands which are not written yet
+func (writer *Writer) GetPendingCount() int64 {
+	return atomic.LoadInt64(&writer.pending)
+}
+
+func (writer *Writer) Ping(ctx context.Context) error {
+	return writer.connections.Ping(ctx)
+}
+
+// GetRetryingDuration returns the longest time writer has been retrying
+// failed writes or truncation
+func (writer *Writer) GetRetryingDuration() time.Duration {
+
+	duration := getRetryingDuration(&writer.retryingSince)
+	for _, shard := range writer.shards {
+		d := shard.GetRetryingDuration()
+		if d > duration {
+			duration = d
+		}
+	}
+
+	return duration
+}
+
+func (writer *Writer) setRetrying(retrying bool) {
+	setRetrying(&writer.retryingSince, retrying)
+}
+
+// sleep waits before retrying, it returns false if writer is closing so that
+// commands are left unacknowledged to be delivered again.
+func (writer *Writer) sleep(d time.Duration) bool {
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-writer.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
 
-			model = mongo.NewUpdateOneModel().SetFilter(bson.M{record.PrimaryKey: value}).SetUpdate(bson.M{"$set": doc})
+// Drain waits for all commands to be written and acknowledged
+func (writer *Writer) Drain(timeout time.Duration) error {
 
-		case gravity_sdk_types_record.Method_INSERT:
-			for _, field := range record.Fields {
-				doc[field.Name] = gravity_sdk_types_record.GetValue(field.Value)
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for writer.GetPendingCount() > 0 {
+		select {
+		case <-deadline:
+			return fmt.Errorf("Timeout waiting for %d pending commands", writer.GetPendingCount())
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Close stops writer. Commands which were not written are given up and
+// retrying writes are aborted, then connections are closed.
+func (writer *Writer) Close() {
+
+	// Stop receiving commands and wait for dispatcher to exit
+	close(writer.stop)
+	writer.wg.Wait()
+
+	// Wait for shards to finish chunks pushed already
+	for _, shard := range writer.shards {
+		shard.Close()
+	}
+
+	writer.checkpoints.Close()
+
+	err := writer.deadLetter.Close()
+	if err != nil {
+		log.Error(err)
+	}
+
+	err = writer.connections.Disconnect()
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (writer *Writer) processData(shard *Shard, dbCommands []*DBCommand) {
+
+	if writer.coalesce {
+		dbCommands = coalesceCommands(dbCommands)
+
+		for _, cmd := range dbCommands {
+			if len(cmd.merged) > 1 {
+				metrics.CommandsCoalesced.WithLabelValues(writer.connections.getLabels(cmd.Record.Table)...).Add(float64(len(cmd.merged) - 1))
 			}
+		}
+	}
+
+	colls := make(map[string]*CollectionRecord, 0)
+	//var models []mongo.WriteModel
+	//var cmds []*DBCommand
+	for _, cmd := range dbCommands {
 
-			model = mongo.NewInsertOneModel().SetDocument(doc)
+		model, err := writer.prepareModel(cmd)
+		if err != nil {
+			writer.reject(cmd, err)
+			continue
+		}
 
+		if model == nil {
+			writer.complete(cmd)
+			continue
 		}
 
 		// Getting status for specific table
@@ -163,94 +450,288 @@ func (writer *Writer) processData(dbCommands []*DBCommand) {
 		collectionRecord.cmds = append(collectionRecord.cmds, cmd)
 	}
 
-	opts := options.BulkWrite().SetOrdered(true)
-
 	// Perform updates for each table
 	for table, colRecord := range colls {
 
-		collection := mdb.Collection(table)
-		cmds := colRecord.cmds
-		models := colRecord.models
+		// Getting collection
+		connector, collection, release, err := writer.connections.GetCollection(table)
+		if err != nil {
+			for _, cmd := range colRecord.cmds {
+				writer.reject(cmd, err)
+			}
+
+			continue
+		}
+
+		writer.ensurePrimaryKeyIndex(connector, collection, colRecord.cmds[0])
+
+		// Commands are completed after their events are kept in history
+		written := writer.writeCollection(shard, collection, colRecord.cmds, colRecord.models)
+		if writer.writeHistory(shard, written) {
+			for _, cmd := range written {
+				writer.complete(cmd)
+			}
+		}
+
+		release()
+	}
+
+}
+
+func (writer *Writer) ensurePrimaryKeyIndex(connector *MongoDBConnector, collection *mongo.Collection, cmd *DBCommand) {
+
+	if !writer.ensureIndex || writer.usePrimaryKeyAsID(cmd) || isEmbedded(cmd) {
+		return
+	}
+
+	keys := getPrimaryKeys(cmd)
+	if len(keys) == 0 {
+		return
+	}
+
+	connector.EnsurePrimaryKeyIndex(collection, keys)
+}
+
+// writeCollection returns commands which were written, commands moved to dead
+// letter queue are completed already.
+func (writer *Writer) writeCollection(shard *Shard, collection *mongo.Collection, cmds []*DBCommand, models []mongo.WriteModel) []*DBCommand {
+
+	if writer.transaction {
+		return writer.writeTransaction(shard, collection, cmds, models)
+	}
+
+	return writer.writeModels(shard, collection, cmds, models)
+}
+
+func (writer *Writer) writeModels(shard *Shard, collection *mongo.Collection, cmds []*DBCommand, models []mongo.WriteModel) []*DBCommand {
+
+	opts := options.BulkWrite().SetOrdered(writer.ordered)
+	name := collection.Name()
+	labels := writer.connections.getLabels(cmds[0].Record.Table)
+	written := make([]*DBCommand, 0, len(cmds))
 
-		for {
+	for len(models) > 0 {
 
-			result, err := collection.BulkWrite(context.Background(), models, opts)
+		metrics.BulkWriteBatchSize.WithLabelValues(labels...).Observe(float64(len(models)))
 
-			// Commands was finished
-			total := result.InsertedCount + result.ModifiedCount + result.DeletedCount
-			for _, cmd := range cmds[:total] {
-				writer.completionHandler(cmd)
+		startTime := time.Now()
+		_, err := collection.BulkWrite(context.Background(), models, opts)
+		metrics.BulkWriteDuration.WithLabelValues(labels...).Observe(time.Since(startTime).Seconds())
+		if err == nil {
+			shard.setRetrying(false)
+			return append(written, cmds...)
+		}
+
+		// No idea which commands were applied, so perform all of them again in 3 seconds
+		bwe, ok := err.(mongo.BulkWriteException)
+		if !ok || len(bwe.WriteErrors) == 0 {
+			log.WithFields(log.Fields{
+				"collection": name,
+			}).Error(err)
+			metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[ErrorClassRetryable])...).Inc()
+			metrics.Retries.WithLabelValues(labels...).Inc()
+			shard.setRetrying(true)
+			if !writer.sleep(3 * time.Second) {
+				return written
+			}
+
+			continue
+		}
+
+		pendingCmds := make([]*DBCommand, 0)
+		pendingModels := make([]mongo.WriteModel, 0)
+		shouldWait := false
+		for _, result := range partitionBulkWrite(cmds, models, bwe, writer.ordered) {
+
+			switch result.status {
+			case writeStatusWritten:
+				written = append(written, result.cmd)
+				continue
+			case writeStatusUnreached:
+				pendingCmds = append(pendingCmds, result.cmd)
+				pendingModels = append(pendingModels, result.model)
+				continue
 			}
 
-			// Update cursor
-			cmds = cmds[total:]
-			models = models[total:]
+			cmd, we := result.cmd, result.err
 
-			// Perform the rest of updates in 3 seconds
+			// Embedded record was pushed before
+			pushed, err := isPushed(collection, cmd, we)
 			if err != nil {
 				log.Error(err)
-				time.Sleep(3 * time.Second)
+			} else if pushed {
+				written = append(written, cmd)
 				continue
 			}
 
-			break
+			class := ClassifyWriteError(we)
+			metrics.WriteErrors.WithLabelValues(labels.with(ErrorClassNames[class])...).Inc()
+
+			// Move poison record to dead letter queue then keep going
+			if class == ErrorClassPermanent {
+				err := writer.deadLetter.Push(cmd, we)
+				if err == nil {
+					writer.complete(cmd)
+					continue
+				}
+
+				log.Error(err)
+			} else {
+				log.WithFields(log.Fields{
+					"collection": name,
+					"code":       we.Code,
+				}).Error(we.Message)
+			}
+
+			pendingCmds = append(pendingCmds, cmd)
+			pendingModels = append(pendingModels, result.model)
+			shouldWait = true
+		}
+
+		// Update cursor
+		cmds = pendingCmds
+		models = pendingModels
+
+		// Perform the rest of updates in 3 seconds
+		if shouldWait {
+			metrics.Retries.WithLabelValues(labels...).Inc()
+			shard.setRetrying(true)
+			if !writer.sleep(3 * time.Second) {
+				return written
+			}
+
+			continue
+		}
+
+		shard.setRetrying(false)
+	}
+
+	return written
+}
+
+type writeStatus int
+
+const (
+	writeStatusWritten writeStatus = iota
+	writeStatusFailed
+	writeStatusUnreached
+)
+
+type writeResult struct {
+	cmd    *DBCommand
+	model  mongo.WriteModel
+	status writeStatus
+	err    mongo.WriteError
+}
+
+// partitionBulkWrite tells what happened to each command of failed bulk write,
+// results are in the order of commands so that the rest can be retried in order.
+func partitionBulkWrite(cmds []*DBCommand, models []mongo.WriteModel, bwe mongo.BulkWriteException, ordered bool) []*writeResult {
+
+	// Driver maps index of write error back to models given even if they
+	// were sent in several batches
+	writeErrors := make(map[int]mongo.WriteError, len(bwe.WriteErrors))
+	for _, we := range bwe.WriteErrors {
+		writeErrors[we.Index] = we.WriteError
+	}
+
+	// Ordered writes stop at the first failed command
+	reached := len(models)
+	if ordered && len(bwe.WriteErrors) > 0 {
+		reached = bwe.WriteErrors[0].Index + 1
+	}
+
+	results := make([]*writeResult, 0, len(cmds))
+	for i, cmd := range cmds {
+
+		result := &writeResult{
+			cmd:   cmd,
+			model: models[i],
 		}
+
+		if i >= reached {
+			result.status = writeStatusUnreached
+		} else if we, failed := writeErrors[i]; failed {
+			result.status = writeStatusFailed
+			result.err = we
+		}
+
+		results = append(results, result)
 	}
 
+	return results
+}
+
+// Begin is called before commands of event are pushed, checkpoint of pipeline
+// stays before the event until Seal is called.
+func (writer *Writer) Begin(pipelineID uint64, sequence uint64) {
+	writer.checkpoints.Begin(pipelineID, sequence)
+}
+
+// Seal is called after all commands of event were pushed
+func (writer *Writer) Seal(pipelineID uint64, sequence uint64) {
+	writer.checkpoints.Seal(pipelineID, sequence)
 }
 
-func (writer *Writer) ProcessData(reference interface{}, record *gravity_sdk_types_record.Record, tables []string) error {
+func (writer *Writer) ProcessData(reference interface{}, pipelineID uint64, sequence uint64, record *gravity_sdk_types_record.Record, target *rule.Target) (bool, error) {
+
+	cmd := &DBCommand{
+		PipelineID: pipelineID,
+		Sequence:   sequence,
+		Reference:  reference,
+		Record:     record,
+		Target:     target,
+	}
 
 	switch record.Method {
 	case gravity_sdk_types_record.Method_DELETE:
-		return writer.DeleteRecord(reference, record, tables)
+		return writer.DeleteRecord(cmd)
 	case gravity_sdk_types_record.Method_UPDATE:
-		return writer.UpdateRecord(reference, record, tables)
+		return writer.UpdateRecord(cmd)
 	case gravity_sdk_types_record.Method_INSERT:
-		return writer.InsertRecord(reference, record, tables)
+		return writer.InsertRecord(cmd)
+	case gravity_sdk_types_record.Method_TRUNCATE:
+		return writer.TruncateRecord(cmd)
 	}
 
-	return nil
+	return false, nil
 
 }
 
-func (writer *Writer) InsertRecord(reference interface{}, record *gravity_sdk_types_record.Record, tables []string) error {
+func (writer *Writer) InsertRecord(cmd *DBCommand) (bool, error) {
 
-	writer.commands <- &DBCommand{
-		Reference: reference,
-		Record:    record,
-		Tables:    tables,
+	err := writer.push(cmd)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
-func (writer *Writer) UpdateRecord(reference interface{}, record *gravity_sdk_types_record.Record, tables []string) error {
+func (writer *Writer) UpdateRecord(cmd *DBCommand) (bool, error) {
 
-	if record.PrimaryKey == "" {
-		return nil
+	if len(getPrimaryKeys(cmd)) == 0 {
+		return false, nil
 	}
 
-	writer.commands <- &DBCommand{
-		Reference: reference,
-		Record:    record,
-		Tables:    tables,
+	err := writer.push(cmd)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
-func (writer *Writer) DeleteRecord(reference interface{}, record *gravity_sdk_types_record.Record, tables []string) error {
+func (writer *Writer) DeleteRecord(cmd *DBCommand) (bool, error) {
 
-	if record.PrimaryKey == "" {
-		return nil
+	if len(getPrimaryKeys(cmd)) == 0 {
+		return false, nil
 	}
 
-	writer.commands <- &DBCommand{
-		Reference: reference,
-		Record:    record,
-		Tables:    tables,
+	err := writer.push(cmd)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
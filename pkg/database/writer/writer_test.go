@@ -0,0 +1,208 @@
+package writer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/database"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// startTestWriter starts writer without connecting to database. Records
+// without primary key are rejected in upsert mode, so commands are completed
+// without writing anything.
+func startTestWriter(t *testing.T) (*Writer, *int64) {
+
+	viper.Set("bufferInput.chunkSize", 100)
+	viper.Set("bufferInput.timeout", 10)
+	viper.Set("writer.writeMode", WriteModeUpsert)
+	viper.Set("writer.workerCount", 4)
+
+	writer := NewWriter()
+
+	var completed int64
+	writer.SetCompletionHandler(func(database.DBCommand) {
+		atomic.AddInt64(&completed, 1)
+	})
+
+	err := writer.checkpoints.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.wg.Add(1)
+	go writer.run()
+
+	return writer, &completed
+}
+
+func TestWriterDrainAndClose(t *testing.T) {
+
+	writer, completed := startTestWriter(t)
+
+	for i := 0; i < 1000; i++ {
+		_, err := writer.ProcessData(nil, 1, uint64(i+1), newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := writer.Drain(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Close()
+
+	if atomic.LoadInt64(completed) != 1000 {
+		t.Fatalf("expected 1000 commands completed, got %d", atomic.LoadInt64(completed))
+	}
+}
+
+func TestWriterCloseWhileReceiving(t *testing.T) {
+
+	writer, completed := startTestWriter(t)
+
+	var accepted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				enqueued, err := writer.ProcessData(nil, 1, 1, newRecord(gravity_sdk_types_record.Method_INSERT, "", "name", "fred"), nil)
+				if err == ErrWriterClosed {
+					return
+				}
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if enqueued {
+					atomic.AddInt64(&accepted, 1)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	writer.Close()
+	wg.Wait()
+
+	// Commands left in queue are given up to be delivered again
+	if atomic.LoadInt64(completed) > atomic.LoadInt64(&accepted) {
+		t.Fatalf("completed %d commands more than accepted %d", atomic.LoadInt64(completed), atomic.LoadInt64(&accepted))
+	}
+
+	if writer.Truncate(&rule.Target{Collection: "users"}) != ErrWriterClosed {
+		t.Fatal("expected truncation to fail after writer was closed")
+	}
+}
+
+func TestValidateTargets(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		writeMode string
+		targets   []*rule.Target
+		fails     bool
+	}{
+		{"insert mode", WriteModeInsert, []*rule.Target{{Collection: "users"}}, false},
+		{"upsert mode with primary keys", WriteModeUpsert, []*rule.Target{{Collection: "users", PrimaryKeys: []string{"id"}}}, false},
+		{"upsert mode without primary keys", WriteModeUpsert, []*rule.Target{{Collection: "users", PrimaryKeys: []string{"id"}}, {Collection: "orders"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := newTestWriter(test.writeMode).validateTargets(test.targets)
+			if (err != nil) != test.fails {
+				t.Fatalf("expected failure %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
+
+func TestPartitionBulkWrite(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		ordered  bool
+		errors   map[int]int
+		expected []writeStatus
+	}{
+		{
+			name:     "ordered stops at failed command",
+			ordered:  true,
+			errors:   map[int]int{1: 11000},
+			expected: []writeStatus{writeStatusWritten, writeStatusFailed, writeStatusUnreached, writeStatusUnreached},
+		},
+		{
+			name:     "ordered fails at the last command",
+			ordered:  true,
+			errors:   map[int]int{3: 112},
+			expected: []writeStatus{writeStatusWritten, writeStatusWritten, writeStatusWritten, writeStatusFailed},
+		},
+		{
+			name:     "unordered keeps going",
+			ordered:  false,
+			errors:   map[int]int{0: 121, 2: 112},
+			expected: []writeStatus{writeStatusFailed, writeStatusWritten, writeStatusFailed, writeStatusWritten},
+		},
+		{
+			name:     "unordered with all failed",
+			ordered:  false,
+			errors:   map[int]int{0: 11000, 1: 11000, 2: 11000, 3: 11000},
+			expected: []writeStatus{writeStatusFailed, writeStatusFailed, writeStatusFailed, writeStatusFailed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			cmds := make([]*DBCommand, 0, len(test.expected))
+			models := make([]mongo.WriteModel, 0, len(test.expected))
+			for i := range test.expected {
+				cmds = append(cmds, &DBCommand{Sequence: uint64(i + 1)})
+				models = append(models, mongo.NewInsertOneModel())
+			}
+
+			// Driver reports write errors in order of index
+			var bwe mongo.BulkWriteException
+			for i := range test.expected {
+				if code, ok := test.errors[i]; ok {
+					bwe.WriteErrors = append(bwe.WriteErrors, mongo.BulkWriteError{
+						WriteError: mongo.WriteError{Index: i, Code: code},
+					})
+				}
+			}
+
+			results := partitionBulkWrite(cmds, models, bwe, test.ordered)
+			if len(results) != len(test.expected) {
+				t.Fatalf("expected %d results, got %d", len(test.expected), len(results))
+			}
+
+			for i, result := range results {
+				if result.cmd != cmds[i] || result.model != models[i] {
+					t.Fatalf("result %d is not in order of commands", i)
+				}
+
+				if result.status != test.expected[i] {
+					t.Fatalf("result %d: expected status %d, got %d", i, test.expected[i], result.status)
+				}
+
+				if result.status == writeStatusFailed && result.err.Code != test.errors[i] {
+					t.Fatalf("result %d: expected error code %d, got %d", i, test.errors[i], result.err.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gravity_transmitter_mongodb"
+
+var (
+	RecordsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "records_received_total",
+		Help:      "Number of records received from gravity",
+	}, []string{"collection", "pipeline"})
+
+	CommandsBuffered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_buffered_total",
+		Help:      "Number of commands pushed to writer",
+	}, []string{"connection", "database", "collection"})
+
+	BulkWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bulk_write_duration_seconds",
+		Help:      "Latency of bulk writes",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"connection", "database", "collection"})
+
+	BulkWriteBatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bulk_write_batch_size",
+		Help:      "Number of write models in a bulk write",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"connection", "database", "collection"})
+
+	CommandsCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_coalesced_total",
+		Help:      "Number of commands merged into another command on the same document",
+	}, []string{"connection", "database", "collection"})
+
+	CommandsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_skipped_total",
+		Help:      "Number of commands skipped because newer events were applied",
+	}, []string{"connection", "database", "collection"})
+
+	WriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "write_errors_total",
+		Help:      "Number of write errors by class",
+	}, []string{"connection", "database", "collection", "class"})
+
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retries_total",
+		Help:      "Number of bulk write retries",
+	}, []string{"connection", "database", "collection"})
+
+	Rotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_rotations_total",
+		Help:      "Number of MongoDB client rotations caused by changes of credentials or certificates",
+	}, []string{"connection", "result"})
+
+	AcksSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "acks_sent_total",
+		Help:      "Number of messages acknowledged to gravity",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		RecordsReceived,
+		CommandsBuffered,
+		BulkWriteDuration,
+		BulkWriteBatchSize,
+		CommandsCoalesced,
+		CommandsSkipped,
+		WriteErrors,
+		Retries,
+		Rotations,
+		AcksSent,
+	)
+}
+
+// RegisterGauge registers gauge which is evaluated when metrics are collected
+func RegisterGauge(name string, help string, fn func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
@@ -0,0 +1,242 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CappedOptions struct {
+	Size int64 `json:"size"`
+	Max  int64 `json:"max"`
+}
+
+type TimeSeriesOptions struct {
+	TimeField          string `json:"timeField"`
+	MetaField          string `json:"metaField"`
+	Granularity        string `json:"granularity"`
+	ExpireAfterSeconds int64  `json:"expireAfterSeconds"`
+}
+
+type ValidatorOptions struct {
+	Schema map[string]interface{} `json:"schema"`
+	Level  string                 `json:"level"`
+	Action string                 `json:"action"`
+}
+
+type CollectionOptions struct {
+	Capped     *CappedOptions     `json:"capped"`
+	TimeSeries *TimeSeriesOptions `json:"timeSeries"`
+	Clustered  bool               `json:"clustered"`
+	Collation  *options.Collation `json:"collation"`
+	Validator  *ValidatorOptions  `json:"validator"`
+}
+
+type IndexRule struct {
+	Name                    string                 `json:"name"`
+	Keys                    []string               `json:"keys"`
+	Unique                  bool                   `json:"unique"`
+	Sparse                  bool                   `json:"sparse"`
+	ExpireAfterSeconds      *int32                 `json:"expireAfterSeconds"`
+	PartialFilterExpression map[string]interface{} `json:"partialFilterExpression"`
+	Collation               *options.Collation     `json:"collation"`
+}
+
+func (opts *CollectionOptions) Validate() error {
+
+	if opts.Capped != nil && opts.Capped.Size <= 0 {
+		return fmt.Errorf("size of capped collection is required")
+	}
+
+	if opts.TimeSeries != nil && len(opts.TimeSeries.TimeField) == 0 {
+		return fmt.Errorf("timeField of time-series collection is required")
+	}
+
+	if opts.Capped != nil && (opts.TimeSeries != nil || opts.Clustered) {
+		return fmt.Errorf("capped collection cannot be time-series or clustered")
+	}
+
+	return nil
+}
+
+// GetCreateOptions returns options for creating collection
+func (opts *CollectionOptions) GetCreateOptions() *options.CreateCollectionOptions {
+
+	createOpts := options.CreateCollection()
+
+	if opts.Capped != nil {
+		createOpts.SetCapped(true).SetSizeInBytes(opts.Capped.Size)
+		if opts.Capped.Max > 0 {
+			createOpts.SetMaxDocuments(opts.Capped.Max)
+		}
+	}
+
+	if opts.TimeSeries != nil {
+		tsOpts := options.TimeSeries().SetTimeField(opts.TimeSeries.TimeField)
+		if len(opts.TimeSeries.MetaField) > 0 {
+			tsOpts.SetMetaField(opts.TimeSeries.MetaField)
+		}
+
+		if len(opts.TimeSeries.Granularity) > 0 {
+			tsOpts.SetGranularity(opts.TimeSeries.Granularity)
+		}
+
+		createOpts.SetTimeSeriesOptions(tsOpts)
+
+		if opts.TimeSeries.ExpireAfterSeconds > 0 {
+			createOpts.SetExpireAfterSeconds(opts.TimeSeries.ExpireAfterSeconds)
+		}
+	}
+
+	if opts.Clustered {
+		createOpts.SetClusteredIndex(bson.D{
+			{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}},
+			{Key: "unique", Value: true},
+		})
+	}
+
+	if opts.Collation != nil {
+		createOpts.SetCollation(opts.Collation)
+	}
+
+	if opts.Validator != nil {
+		createOpts.SetValidator(bson.M{
+			"$jsonSchema": normalizeJSON(opts.Validator.Schema),
+		})
+
+		if len(opts.Validator.Level) > 0 {
+			createOpts.SetValidationLevel(opts.Validator.Level)
+		}
+
+		if len(opts.Validator.Action) > 0 {
+			createOpts.SetValidationAction(opts.Validator.Action)
+		}
+	}
+
+	return createOpts
+}
+
+func (index *IndexRule) Validate() error {
+
+	if len(index.Keys) == 0 {
+		return fmt.Errorf("keys of index are required")
+	}
+
+	for _, key := range index.Keys {
+		name, _ := parseIndexKey(key)
+		if len(name) == 0 {
+			return fmt.Errorf("invalid index key: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// GetKeys returns index specification. Keys are declared as "field" for
+// ascending, "-field" for descending and "field:type" for special indexes
+// such as "location:2dsphere" or "content:text".
+func (index *IndexRule) GetKeys() bson.D {
+
+	keys := make(bson.D, 0, len(index.Keys))
+	for _, key := range index.Keys {
+		name, value := parseIndexKey(key)
+		keys = append(keys, bson.E{Key: name, Value: value})
+	}
+
+	return keys
+}
+
+// GetName returns name of index which is generated the same way as MongoDB does if not specified
+func (index *IndexRule) GetName() string {
+
+	if len(index.Name) > 0 {
+		return index.Name
+	}
+
+	return GetIndexName(index.GetKeys())
+}
+
+func (index *IndexRule) GetIndexOptions() *options.IndexOptions {
+
+	opts := options.Index().SetName(index.GetName())
+
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+
+	if index.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*index.ExpireAfterSeconds)
+	}
+
+	if index.PartialFilterExpression != nil {
+		opts.SetPartialFilterExpression(normalizeJSON(index.PartialFilterExpression))
+	}
+
+	if index.Collation != nil {
+		opts.SetCollation(index.Collation)
+	}
+
+	return opts
+}
+
+func GetIndexName(keys bson.D) string {
+
+	parts := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+
+	return strings.Join(parts, "_")
+}
+
+func parseIndexKey(key string) (string, interface{}) {
+
+	if strings.HasPrefix(key, "-") {
+		return key[1:], int32(-1)
+	}
+
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return key, int32(1)
+}
+
+// normalizeJSON converts numbers decoded from rules to native types so they
+// are encoded as BSON numbers rather than strings.
+func normalizeJSON(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			m[k] = normalizeJSON(v)
+		}
+
+		return m
+	case []interface{}:
+		arr := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			arr = append(arr, normalizeJSON(v))
+		}
+
+		return arr
+	}
+
+	return v
+}
@@ -0,0 +1,35 @@
+package rule
+
+import (
+	"errors"
+)
+
+// EmbedRule routes records into an array field of parent documents
+type EmbedRule struct {
+	Field      string `json:"field"`
+	ForeignKey string `json:"foreignKey"`
+	ParentKey  string `json:"parentKey"`
+}
+
+func (embed *EmbedRule) Validate() error {
+
+	if len(embed.Field) == 0 {
+		return errors.New("embed: field is required")
+	}
+
+	if len(embed.ForeignKey) == 0 {
+		return errors.New("embed: foreignKey is required")
+	}
+
+	return validatePath(embed.Field)
+}
+
+// GetParentKey returns field of parent document which is referred by foreign key
+func (embed *EmbedRule) GetParentKey() string {
+
+	if len(embed.ParentKey) == 0 {
+		return "_id"
+	}
+
+	return embed.ParentKey
+}
@@ -0,0 +1,30 @@
+package rule
+
+import (
+	"strings"
+)
+
+// HistoryRule appends every event to a companion collection
+type HistoryRule struct {
+	Collection string `json:"collection"`
+}
+
+// GetCollection returns name of history collection, it is named after target
+// collection and located in the same database by default.
+func (history *HistoryRule) GetCollection(target *Target) string {
+
+	if len(history.Collection) == 0 {
+		return target.Collection + "_history"
+	}
+
+	if strings.Contains(history.Collection, "/") {
+		return history.Collection
+	}
+
+	name, err := ParseCollectionName(target.Collection)
+	if err != nil {
+		return history.Collection
+	}
+
+	return name.Sibling(history.Collection)
+}
@@ -0,0 +1,59 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollectionName is where target collection is located. Target can be named as
+// "collection", "database/collection" or "connection/database/collection".
+type CollectionName struct {
+	Connection string
+	Database   string
+	Collection string
+}
+
+func ParseCollectionName(name string) (*CollectionName, error) {
+
+	parts := strings.Split(name, "/")
+	for _, part := range parts {
+		if len(part) == 0 {
+			return nil, fmt.Errorf("invalid collection name \"%s\"", name)
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		return &CollectionName{
+			Collection: parts[0],
+		}, nil
+	case 2:
+		return &CollectionName{
+			Database:   parts[0],
+			Collection: parts[1],
+		}, nil
+	case 3:
+		return &CollectionName{
+			Connection: parts[0],
+			Database:   parts[1],
+			Collection: parts[2],
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid collection name \"%s\"", name)
+}
+
+// Sibling returns name of another collection in the same database
+func (name *CollectionName) Sibling(collection string) string {
+
+	parts := make([]string, 0, 3)
+	if len(name.Connection) > 0 {
+		parts = append(parts, name.Connection)
+	}
+
+	if len(name.Database) > 0 || len(name.Connection) > 0 {
+		parts = append(parts, name.Database)
+	}
+
+	return strings.Join(append(parts, collection), "/")
+}
@@ -0,0 +1,73 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCollectionName(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		expected *CollectionName
+		fails    bool
+	}{
+		{name: "users", expected: &CollectionName{Collection: "users"}},
+		{name: "crm/users", expected: &CollectionName{Database: "crm", Collection: "users"}},
+		{name: "archive/crm/users", expected: &CollectionName{Connection: "archive", Database: "crm", Collection: "users"}},
+		{name: "", fails: true},
+		{name: "/users", fails: true},
+		{name: "crm/", fails: true},
+		{name: "crm//users", fails: true},
+		{name: "a/b/c/d", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			name, err := ParseCollectionName(test.name)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", name)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(name, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestSibling(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"users", "users_history"},
+		{"crm/users", "crm/users_history"},
+		{"archive/crm/users", "archive/crm/users_history"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			name, err := ParseCollectionName(test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sibling := name.Sibling("users_history")
+			if sibling != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, sibling)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+type SubscriptionConfig map[string][]*Target
+
+type RuleConfig struct {
+	Subscriptions SubscriptionConfig `json:"subscriptions"`
+}
+
+func LoadFile(filename string) (*RuleConfig, error) {
+
+	// Open and read config file
+	jsonFile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer jsonFile.Close()
+
+	byteValue, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(byteValue)
+}
+
+func Parse(data []byte) (*RuleConfig, error) {
+
+	// Parse config
+	var config RuleConfig
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (config *RuleConfig) Validate() error {
+
+	for collection, targets := range config.Subscriptions {
+		for _, target := range targets {
+			err := target.Validate()
+			if err != nil {
+				return fmt.Errorf("Invalid rule for %s: %v", collection, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetCollectionMap returns target collection names for each gravity collection
+func (config *RuleConfig) GetCollectionMap() map[string][]string {
+
+	colMap := make(map[string][]string, len(config.Subscriptions))
+	for collection, targets := range config.Subscriptions {
+		colMap[collection] = GetTargetNames(targets)
+	}
+
+	return colMap
+}
+
+func GetTargetNames(targets []*Target) []string {
+
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Collection)
+	}
+
+	return names
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+
+	// Keep integers as they are instead of float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
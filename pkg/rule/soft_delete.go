@@ -0,0 +1,56 @@
+package rule
+
+import (
+	"errors"
+)
+
+// SoftDeleteRule marks documents as deleted instead of removing them
+type SoftDeleteRule struct {
+	Field          string `json:"field"`
+	TimestampField string `json:"timestampField"`
+
+	// Seconds to keep soft-deleted documents, they are kept forever if it is zero
+	Retention int32 `json:"retention"`
+}
+
+func (softDelete *SoftDeleteRule) Validate() error {
+
+	if softDelete.Retention < 0 {
+		return errors.New("softDelete: retention cannot be negative")
+	}
+
+	return nil
+}
+
+func (softDelete *SoftDeleteRule) GetField() string {
+
+	if len(softDelete.Field) == 0 {
+		return "_deleted"
+	}
+
+	return softDelete.Field
+}
+
+func (softDelete *SoftDeleteRule) GetTimestampField() string {
+
+	if len(softDelete.TimestampField) == 0 {
+		return "_deletedAt"
+	}
+
+	return softDelete.TimestampField
+}
+
+// GetIndex returns TTL index which removes soft-deleted documents after retention
+func (softDelete *SoftDeleteRule) GetIndex() *IndexRule {
+
+	if softDelete.Retention == 0 {
+		return nil
+	}
+
+	retention := softDelete.Retention
+
+	return &IndexRule{
+		Keys:               []string{softDelete.GetTimestampField()},
+		ExpireAfterSeconds: &retention,
+	}
+}
@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ArrayElement struct {
+	Array string
+	Index int
+}
+
+func (target *Target) prepareStructure() error {
+
+	for name, path := range target.Paths {
+		err := validatePath(path)
+		if err != nil {
+			return fmt.Errorf("path of field %s: %v", name, err)
+		}
+	}
+
+	target.elements = make(map[string]*ArrayElement)
+	for array, fields := range target.Arrays {
+
+		err := validatePath(array)
+		if err != nil {
+			return fmt.Errorf("array %s: %v", array, err)
+		}
+
+		for i, name := range fields {
+			if _, ok := target.elements[name]; ok {
+				return fmt.Errorf("array %s: field %s is already collected into another array", array, name)
+			}
+
+			target.elements[name] = &ArrayElement{
+				Array: array,
+				Index: i,
+			}
+		}
+	}
+
+	return target.checkPathConflicts()
+}
+
+// checkPathConflicts makes sure no path is placed inside another one, MongoDB
+// rejects such updates and documents could not be built.
+func (target *Target) checkPathConflicts() error {
+
+	owners := make(map[string]string)
+	for name, path := range target.Paths {
+		if owner, ok := owners[path]; ok {
+			return fmt.Errorf("path %s is used by both %s and field %s", path, owner, name)
+		}
+
+		owners[path] = "field " + name
+	}
+
+	for array := range target.Arrays {
+		if owner, ok := owners[array]; ok {
+			return fmt.Errorf("path %s is used by both %s and array %s", array, owner, array)
+		}
+
+		owners[array] = "array " + array
+	}
+
+	paths := make([]string, 0, len(owners))
+	for path := range owners {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, parent := range paths {
+		for _, path := range paths {
+			if strings.HasPrefix(path, parent+".") {
+				return fmt.Errorf("path %s of %s is inside path %s of %s", path, owners[path], parent, owners[parent])
+			}
+		}
+	}
+
+	return nil
+}
+
+func validatePath(path string) error {
+
+	for _, part := range strings.Split(path, ".") {
+		if len(part) == 0 {
+			return fmt.Errorf("invalid path \"%s\"", path)
+		}
+	}
+
+	return nil
+}
+
+// HasStructure tells whether fields are placed in nested documents or arrays
+func (target *Target) HasStructure() bool {
+	return target != nil && (len(target.Paths) > 0 || len(target.Arrays) > 0 || target.NestDottedFields)
+}
+
+// GetPath returns path where field should be placed in document
+func (target *Target) GetPath(name string) []string {
+
+	if path, ok := target.Paths[name]; ok {
+		return strings.Split(path, ".")
+	}
+
+	// Dots are part of field name unless they are treated as path
+	if target.NestDottedFields {
+		return strings.Split(name, ".")
+	}
+
+	return []string{name}
+}
+
+// GetArrayElement returns position of field which is collected into array
+func (target *Target) GetArrayElement(name string) *ArrayElement {
+	return target.elements[name]
+}
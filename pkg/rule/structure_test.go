@@ -0,0 +1,35 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPrepareStructure(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		data  string
+		fails bool
+	}{
+		{"sibling paths", `{ "collection": "users", "paths": { "city": "address.city", "zip": "address.zip" } }`, false},
+		{"common prefix", `{ "collection": "users", "paths": { "a": "address", "b": "addresses.city" } }`, false},
+		{"path inside another", `{ "collection": "users", "paths": { "a": "address", "b": "address.city" } }`, true},
+		{"the same path", `{ "collection": "users", "paths": { "a": "address", "b": "address" } }`, true},
+		{"path inside array", `{ "collection": "users", "paths": { "a": "tags.first" }, "arrays": { "tags": [ "tag1", "tag2" ] } }`, true},
+		{"array at path", `{ "collection": "users", "paths": { "a": "tags" }, "arrays": { "tags": [ "tag1" ] } }`, true},
+		{"invalid path", `{ "collection": "users", "paths": { "a": "address..city" } }`, true},
+		{"field in two arrays", `{ "collection": "users", "arrays": { "a": [ "tag" ], "b": [ "tag" ] } }`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var target Target
+			err := json.Unmarshal([]byte(test.data), &target)
+			if (err != nil) != test.fails {
+				t.Fatalf("expected failure %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/converter"
+)
+
+const (
+	ComputedTypeConcat   = "concat"
+	ComputedTypeCopy     = "copy"
+	ComputedTypeSyncTime = "syncTime"
+)
+
+type ComputedField struct {
+	Type      string   `json:"type"`
+	Field     string   `json:"field"`
+	Fields    []string `json:"fields"`
+	Separator string   `json:"separator"`
+}
+
+type Target struct {
+	Collection     string                    `json:"collection"`
+	PrimaryKeys    []string                  `json:"primaryKeys"`
+	PrimaryKeyAsID *bool                     `json:"primaryKeyAsID"`
+	Rename         map[string]string         `json:"rename"`
+	Drop           []string                  `json:"drop"`
+	Include        []string                  `json:"include"`
+	Constants      map[string]interface{}    `json:"constants"`
+	Computed       map[string]*ComputedField `json:"computed"`
+
+	// Output types of fields, default type depends on type of value from gravity
+	Types map[string]string `json:"types"`
+
+	// Nested documents and arrays
+	Paths            map[string]string   `json:"paths"`
+	NestDottedFields bool                `json:"nestDottedFields"`
+	Arrays           map[string][]string `json:"arrays"`
+
+	// Write records into array of parent documents instead
+	Embed *EmbedRule `json:"embed"`
+
+	// Mark documents as deleted instead of removing them
+	SoftDelete *SoftDeleteRule `json:"softDelete"`
+
+	// Append every event to history collection as well
+	History *HistoryRule `json:"history"`
+
+	// Spread commands to writers by primary key for hot collection
+	ShardByPrimaryKey bool `json:"shardByPrimaryKey"`
+
+	// Provisioning
+	CollectionOptions *CollectionOptions `json:"collectionOptions"`
+	Indexes           []*IndexRule       `json:"indexes"`
+
+	dropped    map[string]bool
+	included   map[string]bool
+	constants  []*gravity_sdk_types_record.Field
+	converters map[string]converter.Converter
+	elements   map[string]*ArrayElement
+}
+
+// UnmarshalJSON accepts a plain collection name as well as a full target rule
+func (target *Target) UnmarshalJSON(data []byte) error {
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		target.Collection = name
+		return nil
+	}
+
+	type rawTarget Target
+	err := decodeJSON(data, (*rawTarget)(target))
+	if err != nil {
+		return err
+	}
+
+	return target.prepare()
+}
+
+func (target *Target) prepare() error {
+
+	target.dropped = make(map[string]bool, len(target.Drop))
+	for _, name := range target.Drop {
+		target.dropped[name] = true
+	}
+
+	target.included = make(map[string]bool, len(target.Include))
+	for _, name := range target.Include {
+		target.included[name] = true
+	}
+
+	target.constants = make([]*gravity_sdk_types_record.Field, 0, len(target.Constants))
+	for name, v := range target.Constants {
+		value, err := createValue(v)
+		if err != nil {
+			return fmt.Errorf("constant field %s: %v", name, err)
+		}
+
+		target.constants = append(target.constants, &gravity_sdk_types_record.Field{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	target.converters = make(map[string]converter.Converter, len(target.Types))
+	for name, t := range target.Types {
+		fn, ok := converter.Get(t)
+		if !ok {
+			return fmt.Errorf("field %s: unknown type %s", name, t)
+		}
+
+		target.converters[name] = fn
+	}
+
+	return target.prepareStructure()
+}
+
+// GetConverter returns converter for specific field
+func (target *Target) GetConverter(name string) converter.Converter {
+
+	if fn, ok := target.converters[name]; ok {
+		return fn
+	}
+
+	return converter.Convert
+}
+
+func (target *Target) Validate() error {
+
+	if len(target.Collection) == 0 {
+		return errors.New("collection is required")
+	}
+
+	_, err := ParseCollectionName(target.Collection)
+	if err != nil {
+		return err
+	}
+
+	if target.Embed != nil {
+		err := target.Embed.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if target.SoftDelete != nil {
+		if target.Embed != nil {
+			return errors.New("softDelete is not supported by embedded records")
+		}
+
+		err := target.SoftDelete.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if target.CollectionOptions != nil {
+		err := target.CollectionOptions.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, index := range target.Indexes {
+		err := index.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, computed := range target.Computed {
+		switch computed.Type {
+		case ComputedTypeConcat:
+			if len(computed.Fields) == 0 {
+				return fmt.Errorf("computed field %s: fields are required", name)
+			}
+		case ComputedTypeCopy:
+			if len(computed.Field) == 0 {
+				return fmt.Errorf("computed field %s: field is required", name)
+			}
+		case ComputedTypeSyncTime:
+		default:
+			return fmt.Errorf("computed field %s: unknown type %s", name, computed.Type)
+		}
+	}
+
+	return nil
+}
+
+func (target *Target) hasTransform() bool {
+	return len(target.Rename) > 0 ||
+		len(target.Drop) > 0 ||
+		len(target.Include) > 0 ||
+		len(target.Constants) > 0 ||
+		len(target.Computed) > 0
+}
@@ -0,0 +1,194 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+// Transform applies field mapping rules of target to record. Primary key is
+// always kept so that records can still be updated and deleted, computed
+// fields are evaluated against the original fields before anything is
+// dropped or renamed.
+func (target *Target) Transform(record *gravity_sdk_types_record.Record) {
+
+	if !target.hasTransform() {
+		return
+	}
+
+	computed := target.compute(record)
+
+	fields := make([]*gravity_sdk_types_record.Field, 0, len(record.Fields)+len(target.constants)+len(computed))
+	for _, field := range record.Fields {
+
+		if !target.isPrimaryKey(record, field.Name) {
+			if len(target.included) > 0 && !target.included[field.Name] {
+				continue
+			}
+
+			if target.dropped[field.Name] {
+				continue
+			}
+		}
+
+		name, ok := target.Rename[field.Name]
+		if !ok {
+			fields = append(fields, field)
+			continue
+		}
+
+		fields = append(fields, &gravity_sdk_types_record.Field{
+			Name:  name,
+			Value: field.Value,
+		})
+	}
+
+	if name, ok := target.Rename[record.PrimaryKey]; ok {
+		record.PrimaryKey = name
+	}
+
+	fields = append(fields, target.constants...)
+	fields = append(fields, computed...)
+
+	record.Fields = fields
+}
+
+// isPrimaryKey tells whether field is a part of primary key. Primary keys
+// declared in rules are names after renaming.
+func (target *Target) isPrimaryKey(record *gravity_sdk_types_record.Record, name string) bool {
+
+	if name == record.PrimaryKey {
+		return true
+	}
+
+	if renamed, ok := target.Rename[name]; ok {
+		name = renamed
+	}
+
+	for _, key := range target.PrimaryKeys {
+		if key == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (target *Target) compute(record *gravity_sdk_types_record.Record) []*gravity_sdk_types_record.Field {
+
+	if len(target.Computed) == 0 {
+		return nil
+	}
+
+	fields := make([]*gravity_sdk_types_record.Field, 0, len(target.Computed))
+	for name, computed := range target.Computed {
+
+		var value *gravity_sdk_types_record.Value
+
+		switch computed.Type {
+		case ComputedTypeConcat:
+			values := make([]string, 0, len(computed.Fields))
+			for _, fieldName := range computed.Fields {
+				values = append(values, getString(record, fieldName))
+			}
+
+			value, _ = gravity_sdk_types_record.CreateValue(gravity_sdk_types_record.DataType_STRING, strings.Join(values, computed.Separator))
+		case ComputedTypeCopy:
+			field := gravity_sdk_types_record.GetField(record.Fields, computed.Field)
+			if field == nil {
+				continue
+			}
+
+			value = field.Value
+		case ComputedTypeSyncTime:
+			value, _ = gravity_sdk_types_record.CreateValue(gravity_sdk_types_record.DataType_TIME, time.Now())
+		}
+
+		if value == nil {
+			continue
+		}
+
+		fields = append(fields, &gravity_sdk_types_record.Field{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	return fields
+}
+
+// createValue converts constant from rules to value of record. Booleans are
+// encoded as a single byte because SDK encodes them with gob while reading
+// only the lowest bit of the first byte.
+func createValue(data interface{}) (*gravity_sdk_types_record.Value, error) {
+
+	switch v := data.(type) {
+	case bool:
+		value := &gravity_sdk_types_record.Value{
+			Type:  gravity_sdk_types_record.DataType_BOOLEAN,
+			Value: []byte{0},
+		}
+
+		if v {
+			value.Value[0] = 1
+		}
+
+		return value, nil
+	case map[string]interface{}:
+		fields := make([]*gravity_sdk_types_record.Field, 0, len(v))
+		for name, ele := range v {
+			value, err := createValue(ele)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, &gravity_sdk_types_record.Field{
+				Name:  name,
+				Value: value,
+			})
+		}
+
+		return &gravity_sdk_types_record.Value{
+			Type: gravity_sdk_types_record.DataType_MAP,
+			Map: &gravity_sdk_types_record.MapValue{
+				Fields: fields,
+			},
+		}, nil
+	case []interface{}:
+		elements := make([]*gravity_sdk_types_record.Value, 0, len(v))
+		for _, ele := range v {
+			value, err := createValue(ele)
+			if err != nil {
+				return nil, err
+			}
+
+			elements = append(elements, value)
+		}
+
+		return &gravity_sdk_types_record.Value{
+			Type: gravity_sdk_types_record.DataType_ARRAY,
+			Array: &gravity_sdk_types_record.ArrayValue{
+				Elements: elements,
+			},
+		}, nil
+	}
+
+	return gravity_sdk_types_record.GetValueFromInterface(data)
+}
+
+func getString(record *gravity_sdk_types_record.Record, fieldName string) string {
+
+	field := gravity_sdk_types_record.GetField(record.Fields, fieldName)
+	if field == nil {
+		return ""
+	}
+
+	v := gravity_sdk_types_record.GetValue(field.Value)
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
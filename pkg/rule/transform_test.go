@@ -0,0 +1,195 @@
+package rule
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/converter"
+)
+
+func newRecord(primaryKey string, fields ...interface{}) *gravity_sdk_types_record.Record {
+
+	record := &gravity_sdk_types_record.Record{
+		Method:     gravity_sdk_types_record.Method_INSERT,
+		PrimaryKey: primaryKey,
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		v, err := gravity_sdk_types_record.GetValueFromInterface(fields[i+1])
+		if err != nil {
+			panic(err)
+		}
+
+		record.Fields = append(record.Fields, &gravity_sdk_types_record.Field{
+			Name:  fields[i].(string),
+			Value: v,
+		})
+	}
+
+	return record
+}
+
+// getFields returns converted values of record
+func getFields(t *testing.T, record *gravity_sdk_types_record.Record) map[string]interface{} {
+
+	values := make(map[string]interface{}, len(record.Fields))
+	for _, field := range record.Fields {
+		v, err := converter.Convert(field.Value)
+		if err != nil {
+			t.Fatalf("field %s: %v", field.Name, err)
+		}
+
+		values[field.Name] = v
+	}
+
+	return values
+}
+
+func TestTargetUnmarshalJSON(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		data       string
+		collection string
+		fails      bool
+	}{
+		{"plain name", `"users"`, "users", false},
+		{"object", `{ "collection": "users", "drop": [ "password" ] }`, "users", false},
+		{"unknown type", `{ "collection": "users", "types": { "id": "unknown" } }`, "", true},
+		{"invalid", `123`, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var target Target
+			err := json.Unmarshal([]byte(test.data), &target)
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if target.Collection != test.collection {
+				t.Fatalf("expected %s, got %s", test.collection, target.Collection)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		rule       string
+		record     *gravity_sdk_types_record.Record
+		expected   map[string]interface{}
+		primaryKey string
+	}{
+		{
+			name:       "rename and drop",
+			rule:       `{ "collection": "users", "rename": { "id": "userID", "name": "fullName" }, "drop": [ "password" ] }`,
+			record:     newRecord("id", "id", int64(1), "name", "fred", "password", "secret"),
+			expected:   map[string]interface{}{"userID": int64(1), "fullName": "fred"},
+			primaryKey: "userID",
+		},
+		{
+			name:       "include keeps primary key",
+			rule:       `{ "collection": "users", "include": [ "name" ] }`,
+			record:     newRecord("id", "id", int64(1), "name", "fred", "age", int64(30)),
+			expected:   map[string]interface{}{"id": int64(1), "name": "fred"},
+			primaryKey: "id",
+		},
+		{
+			name:       "include keeps composite primary key",
+			rule:       `{ "collection": "items", "primaryKeys": [ "orderID", "line" ], "include": [ "name" ] }`,
+			record:     newRecord("", "orderID", int64(1), "line", int64(2), "name", "pen", "price", int64(30)),
+			expected:   map[string]interface{}{"orderID": int64(1), "line": int64(2), "name": "pen"},
+			primaryKey: "",
+		},
+		{
+			name:       "drop keeps renamed composite primary key",
+			rule:       `{ "collection": "items", "primaryKeys": [ "orderID", "line" ], "rename": { "order_id": "orderID" }, "drop": [ "order_id", "line", "price" ] }`,
+			record:     newRecord("", "order_id", int64(1), "line", int64(2), "price", int64(30)),
+			expected:   map[string]interface{}{"orderID": int64(1), "line": int64(2)},
+			primaryKey: "",
+		},
+		{
+			name:       "constants",
+			rule:       `{ "collection": "users", "constants": { "active": true, "deleted": false, "source": "gravity", "version": 2 } }`,
+			record:     newRecord("id", "id", int64(1)),
+			expected:   map[string]interface{}{"id": int64(1), "active": true, "deleted": false, "source": "gravity", "version": int64(2)},
+			primaryKey: "id",
+		},
+		{
+			name:       "computed",
+			rule:       `{ "collection": "users", "computed": { "fullName": { "type": "concat", "fields": [ "first", "last" ], "separator": " " }, "nick": { "type": "copy", "field": "first" } }, "drop": [ "first" ] }`,
+			record:     newRecord("id", "id", int64(1), "first", "fred", "last", "chien"),
+			expected:   map[string]interface{}{"id": int64(1), "last": "chien", "fullName": "fred chien", "nick": "fred"},
+			primaryKey: "id",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var target Target
+			err := json.Unmarshal([]byte(test.rule), &target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target.Transform(test.record)
+
+			values := getFields(t, test.record)
+			if !reflect.DeepEqual(values, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, values)
+			}
+
+			if test.record.PrimaryKey != test.primaryKey {
+				t.Fatalf("expected primary key %s, got %s", test.primaryKey, test.record.PrimaryKey)
+			}
+		})
+	}
+}
+
+func TestCreateValue(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected interface{}
+	}{
+		{"true", true, true},
+		{"false", false, false},
+		{"string", "gravity", "gravity"},
+		{"number", json.Number("3"), int64(3)},
+		{"null", nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			value, err := createValue(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := converter.Convert(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, v)
+			}
+		})
+	}
+}
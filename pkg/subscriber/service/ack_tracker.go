@@ -0,0 +1,188 @@
+package subscriber
+
+import (
+	"sort"
+	"sync"
+
+	gravity_subscriber "github.com/BrobridgeOrg/gravity-sdk/subscriber"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/watermark"
+)
+
+type ackState struct {
+	expected   int
+	done       int
+	sealed     bool
+	waiting    bool
+	pipelineID uint64
+	sequence   uint64
+}
+
+// ackPipeline keeps messages which were done before the ones ahead of them.
+// Gravity SDK stores only the highest sequence acknowledged for pipeline, so
+// messages have to be acknowledged in order or the ones still being written
+// would never be delivered again after restarting.
+type ackPipeline struct {
+	watermark *watermark.Watermark
+	done      map[uint64][]*gravity_subscriber.Message
+}
+
+// AckTracker acknowledges message after all commands generated from it, and
+// all messages before it in the same pipeline, were written.
+type AckTracker struct {
+	mutex     sync.Mutex
+	messages  map[*gravity_subscriber.Message]*ackState
+	pipelines map[uint64]*ackPipeline
+	closed    bool
+}
+
+func NewAckTracker() *AckTracker {
+	return &AckTracker{
+		messages:  make(map[*gravity_subscriber.Message]*ackState),
+		pipelines: make(map[uint64]*ackPipeline),
+	}
+}
+
+// Begin starts tracking commands of message
+func (tracker *AckTracker) Begin(msg *gravity_subscriber.Message) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	pipelineID, sequence := getPosition(msg)
+
+	tracker.messages[msg] = &ackState{
+		pipelineID: pipelineID,
+		sequence:   sequence,
+	}
+
+	// Snapshot records are not in sequence
+	if sequence == 0 {
+		return
+	}
+
+	pipeline, ok := tracker.pipelines[pipelineID]
+	if !ok {
+		pipeline = &ackPipeline{
+			watermark: watermark.NewWatermark(),
+			done:      make(map[uint64][]*gravity_subscriber.Message),
+		}
+		tracker.pipelines[pipelineID] = pipeline
+	}
+
+	pipeline.watermark.Hold(sequence)
+}
+
+// Add increases number of commands which were pushed to writer for message
+func (tracker *AckTracker) Add(msg *gravity_subscriber.Message) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	state, ok := tracker.messages[msg]
+	if !ok {
+		return
+	}
+
+	state.expected++
+}
+
+// Seal indicates no more commands will be added, message is acknowledged
+// immediately if all of commands were done or there was nothing to write.
+func (tracker *AckTracker) Seal(msg *gravity_subscriber.Message) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	state, ok := tracker.messages[msg]
+	if !ok {
+		return
+	}
+
+	state.sealed = true
+	tracker.tryAck(msg, state)
+}
+
+// Complete is called when one of commands of message was written
+func (tracker *AckTracker) Complete(msg *gravity_subscriber.Message) {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	state, ok := tracker.messages[msg]
+	if !ok {
+		return
+	}
+
+	state.done++
+	tracker.tryAck(msg, state)
+}
+
+func (tracker *AckTracker) tryAck(msg *gravity_subscriber.Message, state *ackState) {
+
+	if !state.sealed || state.done < state.expected || state.waiting {
+		return
+	}
+
+	if state.sequence == 0 {
+		tracker.ack(msg)
+		return
+	}
+
+	// Wait for messages before it
+	state.waiting = true
+	pipeline := tracker.pipelines[state.pipelineID]
+	pipeline.watermark.Release(state.sequence)
+	pipeline.done[state.sequence] = append(pipeline.done[state.sequence], msg)
+
+	mark := pipeline.watermark.Get()
+	sequences := make([]uint64, 0, len(pipeline.done))
+	for seq := range pipeline.done {
+		if seq <= mark {
+			sequences = append(sequences, seq)
+		}
+	}
+
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i] < sequences[j]
+	})
+
+	for _, seq := range sequences {
+		for _, m := range pipeline.done[seq] {
+			tracker.ack(m)
+		}
+
+		delete(pipeline.done, seq)
+	}
+}
+
+func (tracker *AckTracker) ack(msg *gravity_subscriber.Message) {
+
+	delete(tracker.messages, msg)
+
+	// State store was closed already
+	if tracker.closed {
+		return
+	}
+
+	msg.Ack()
+	metrics.AcksSent.Inc()
+}
+
+// Count returns number of messages which are not acknowledged yet
+func (tracker *AckTracker) Count() int {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	return len(tracker.messages)
+}
+
+// Close stops acknowledging messages
+func (tracker *AckTracker) Close() {
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.closed = true
+}
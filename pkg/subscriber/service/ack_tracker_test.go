@@ -0,0 +1,165 @@
+package subscriber
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_subscriber "github.com/BrobridgeOrg/gravity-sdk/subscriber"
+)
+
+func TestAckTracker(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		steps   []string
+		acks    int
+		pending int
+	}{
+		{"nothing to write", []string{"seal"}, 1, 0},
+		{"completed before seal", []string{"add", "add", "complete", "complete", "seal"}, 1, 0},
+		{"completed after seal", []string{"add", "add", "seal", "complete", "complete"}, 1, 0},
+		{"not completed", []string{"add", "add", "seal", "complete"}, 0, 1},
+		{"not sealed", []string{"add", "complete"}, 0, 1},
+		{"duplicated completion", []string{"add", "seal", "complete", "complete"}, 1, 0},
+		{"closed", []string{"add", "close", "seal", "complete"}, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			acks := 0
+			msg := &gravity_subscriber.Message{
+				Callback: func(*gravity_subscriber.Message) {
+					acks++
+				},
+			}
+
+			tracker := NewAckTracker()
+			tracker.Begin(msg)
+
+			for _, step := range test.steps {
+				switch step {
+				case "add":
+					tracker.Add(msg)
+				case "seal":
+					tracker.Seal(msg)
+				case "complete":
+					tracker.Complete(msg)
+				case "close":
+					tracker.Close()
+				}
+			}
+
+			if acks != test.acks {
+				t.Fatalf("expected %d acks, got %d", test.acks, acks)
+			}
+
+			if tracker.Count() != test.pending {
+				t.Fatalf("expected %d pending messages, got %d", test.pending, tracker.Count())
+			}
+		})
+	}
+}
+
+func TestAckTrackerUntrackedMessage(t *testing.T) {
+
+	acks := 0
+	msg := &gravity_subscriber.Message{
+		Callback: func(*gravity_subscriber.Message) {
+			acks++
+		},
+	}
+
+	tracker := NewAckTracker()
+	tracker.Add(msg)
+	tracker.Complete(msg)
+	tracker.Seal(msg)
+
+	if acks != 0 || tracker.Count() != 0 {
+		t.Fatalf("untracked message should be ignored, got %d acks and %d pending", acks, tracker.Count())
+	}
+}
+
+func TestAckTrackerSequenceOrder(t *testing.T) {
+
+	type position struct {
+		pipelineID uint64
+		sequence   uint64
+	}
+
+	tests := []struct {
+		name      string
+		messages  []position
+		completes []int
+		acks      [][]position
+	}{
+		{
+			name:      "completed in order",
+			messages:  []position{{1, 10}, {1, 11}},
+			completes: []int{0, 1},
+			acks:      [][]position{{{1, 10}}, {{1, 10}, {1, 11}}},
+		},
+		{
+			name:      "later sequence completed first",
+			messages:  []position{{1, 10}, {1, 11}},
+			completes: []int{1, 0},
+			acks:      [][]position{{}, {{1, 10}, {1, 11}}},
+		},
+		{
+			name:      "other pipeline is not blocked",
+			messages:  []position{{1, 10}, {2, 11}},
+			completes: []int{1, 0},
+			acks:      [][]position{{{2, 11}}, {{2, 11}, {1, 10}}},
+		},
+		{
+			name:      "snapshot records are not in sequence",
+			messages:  []position{{1, 10}, {1, 0}},
+			completes: []int{1, 0},
+			acks:      [][]position{{{1, 0}}, {{1, 0}, {1, 10}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			acks := make([]position, 0)
+			tracker := NewAckTracker()
+
+			msgs := make([]*gravity_subscriber.Message, 0, len(test.messages))
+			for _, p := range test.messages {
+				p := p
+
+				var payload interface{}
+				if p.sequence == 0 {
+					payload = &gravity_subscriber.SnapshotEvent{PipelineID: p.pipelineID}
+				} else {
+					payload = &gravity_subscriber.DataEvent{PipelineID: p.pipelineID, Sequence: p.sequence}
+				}
+
+				msg := &gravity_subscriber.Message{
+					Payload: payload,
+					Callback: func(*gravity_subscriber.Message) {
+						acks = append(acks, p)
+					},
+				}
+
+				tracker.Begin(msg)
+				tracker.Add(msg)
+				tracker.Seal(msg)
+				msgs = append(msgs, msg)
+			}
+
+			for i, index := range test.completes {
+				tracker.Complete(msgs[index])
+
+				if !reflect.DeepEqual(acks, test.acks[i]) {
+					t.Fatalf("expected acks %v after completing %d, got %v", test.acks[i], index, acks)
+				}
+			}
+
+			if tracker.Count() != 0 {
+				t.Fatalf("expected no pending messages, got %d", tracker.Count())
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package subscriber
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// RuleReloader applies changes of rules file without restarting. Rules are
+// reloaded when content of file is changed or SIGHUP is received.
+type RuleReloader struct {
+	subscriber *Subscriber
+	filename   string
+	checksum   [sha256.Size]byte
+	mutex      sync.Mutex
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+func NewRuleReloader(subscriber *Subscriber, filename string) *RuleReloader {
+
+	reloader := &RuleReloader{
+		subscriber: subscriber,
+		filename:   filename,
+		stop:       make(chan struct{}),
+	}
+
+	// Rules were loaded already
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		reloader.checksum = sha256.Sum256(data)
+	}
+
+	return reloader
+}
+
+func (reloader *RuleReloader) Run() {
+
+	viper.SetDefault("rules.watch", true)
+	viper.SetDefault("rules.watchInterval", 5)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// Polling is disabled, reload on SIGHUP only
+	var tick <-chan time.Time
+	if viper.GetBool("rules.watch") {
+		ticker := time.NewTicker(viper.GetDuration("rules.watchInterval") * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-reloader.stop:
+			return
+		case <-hup:
+			log.Info("Received SIGHUP, reloading rules")
+			reloader.Reload(true)
+		case <-tick:
+			reloader.Reload(false)
+		}
+	}
+}
+
+func (reloader *RuleReloader) Stop() {
+	reloader.stopOnce.Do(func() {
+		close(reloader.stop)
+	})
+}
+
+// Reload loads rules file and applies it if it was changed or force is true.
+// Rules in use are kept if new rules are invalid.
+func (reloader *RuleReloader) Reload(force bool) {
+
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	data, err := ioutil.ReadFile(reloader.filename)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"ruleFile": reloader.filename,
+		}).Errorf("Failed to read rules: %v", err)
+		return
+	}
+
+	checksum := sha256.Sum256(data)
+	if !force && checksum == reloader.checksum {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"ruleFile": reloader.filename,
+	}).Info("Reloading rules...")
+
+	ruleConfig, err := rule.Parse(data)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"ruleFile": reloader.filename,
+		}).Errorf("Invalid rules, keep using the old ones: %v", err)
+
+		// Do not report the same file again
+		reloader.checksum = checksum
+		return
+	}
+
+	err = reloader.apply(ruleConfig)
+	if err != nil {
+		// Retry next time
+		log.WithFields(log.Fields{
+			"ruleFile": reloader.filename,
+		}).Errorf("Failed to apply rules, keep using the old ones: %v", err)
+		return
+	}
+
+	reloader.checksum = checksum
+
+	log.WithFields(log.Fields{
+		"ruleFile":    reloader.filename,
+		"collections": len(ruleConfig.Subscriptions),
+	}).Info("Rules were reloaded")
+}
+
+func (reloader *RuleReloader) apply(ruleConfig *rule.RuleConfig) error {
+
+	subscriber := reloader.subscriber
+	current := subscriber.getRuleConfig()
+
+	// Prepare target collections before any record is routed to them
+	targets := make([]*rule.Target, 0)
+	for _, ts := range ruleConfig.Subscriptions {
+		targets = append(targets, ts...)
+	}
+
+	err := subscriber.app.GetWriter().PrepareTargets(targets)
+	if err != nil {
+		return err
+	}
+
+	// Subscribe to collections added
+	added := make(map[string][]string)
+	for collection, ts := range ruleConfig.Subscriptions {
+		if _, ok := current.Subscriptions[collection]; !ok {
+			added[collection] = rule.GetTargetNames(ts)
+		}
+	}
+
+	// Gravity SDK is not able to unsubscribe, events of collections removed
+	// are still received and acknowledged without writing anything.
+	removed := make(map[string][]string)
+	for collection, ts := range current.Subscriptions {
+		if _, ok := ruleConfig.Subscriptions[collection]; !ok {
+			removed[collection] = rule.GetTargetNames(ts)
+		}
+	}
+
+	// Collections of gravity SDK are not guarded, wait for messages being
+	// dispatched and hold new ones until subscriptions are changed. Snapshots
+	// of pipelines were prepared before reloader started.
+	subscriber.dispatching.Lock()
+	defer subscriber.dispatching.Unlock()
+
+	if len(added) > 0 {
+		log.WithFields(log.Fields{
+			"collections": getCollectionNames(added),
+		}).Info("Subscribing to collections")
+
+		err := subscriber.collections.SubscribeToCollections(added)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		log.WithFields(log.Fields{
+			"collections": getCollectionNames(removed),
+		}).Warn("Collections were removed from rules, events of them are still received but discarded since gravity SDK is not able to unsubscribe")
+	}
+
+	// New rules take effect from the next message
+	subscriber.ruleConfig.Store(ruleConfig)
+
+	return nil
+}
+
+func getCollectionNames(colMap map[string][]string) []string {
+
+	names := make([]string, 0, len(colMap))
+	for name := range colMap {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
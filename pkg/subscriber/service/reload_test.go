@@ -0,0 +1,182 @@
+package subscriber
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	gravity_subscriber "github.com/BrobridgeOrg/gravity-sdk/subscriber"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+)
+
+// testCollections records collections subscribed, like gravity SDK it does not
+// guard them against messages being dispatched.
+type testCollections struct {
+	writer      *testWriter
+	collections map[string][]string
+	dispatching int32
+}
+
+func (c *testCollections) SubscribeToCollections(colMap map[string][]string) error {
+
+	if n := atomic.LoadInt32(&c.writer.dispatching); n > c.dispatching {
+		c.dispatching = n
+	}
+
+	for collection, tables := range colMap {
+		c.collections[collection] = tables
+	}
+
+	return nil
+}
+
+func newDataMessage(collection string, sequence uint64, acked *int32) *gravity_subscriber.Message {
+	return &gravity_subscriber.Message{
+		Payload: &gravity_subscriber.DataEvent{
+			Sequence: sequence,
+			Payload: &gravity_sdk_types_record.Record{
+				Table:  collection,
+				Method: gravity_sdk_types_record.Method_INSERT,
+			},
+		},
+		Callback: func(*gravity_subscriber.Message) {
+			atomic.AddInt32(acked, 1)
+		},
+	}
+}
+
+func writeRules(t *testing.T, filename string, data string) {
+
+	err := ioutil.WriteFile(filename, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadRules(t *testing.T) {
+
+	filename := filepath.Join(t.TempDir(), "subscriptions.json")
+	writeRules(t, filename, `{ "subscriptions": { "accounts": [ "users" ] } }`)
+
+	ruleConfig, err := rule.LoadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriber, writer := newTestSubscriber(t, t.TempDir(), ruleConfig)
+	defer subscriber.CloseStateStore()
+
+	collections := &testCollections{
+		writer:      writer,
+		collections: ruleConfig.GetCollectionMap(),
+	}
+	subscriber.collections = collections
+	reloader := NewRuleReloader(subscriber, filename)
+
+	// Keep dispatching messages while rules are reloaded
+	var acked int32
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := uint64(1); ; seq++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				subscriber.eventHandler(newDataMessage("accounts", seq, &acked))
+				subscriber.eventHandler(newDataMessage("orders", seq, &acked))
+			}
+		}()
+	}
+
+	tests := []struct {
+		name        string
+		rules       string
+		collections map[string][]string
+		targets     map[string][]string
+	}{
+		{
+			name:  "collection added",
+			rules: `{ "subscriptions": { "accounts": [ "users" ], "orders": [ "orders" ] } }`,
+			collections: map[string][]string{
+				"accounts": {"users"},
+				"orders":   {"orders"},
+			},
+			targets: map[string][]string{
+				"accounts": {"users"},
+				"orders":   {"orders"},
+			},
+		},
+		{
+			name:  "collection removed",
+			rules: `{ "subscriptions": { "orders": [ "orders" ] } }`,
+			collections: map[string][]string{
+				"accounts": {"users"},
+				"orders":   {"orders"},
+			},
+			targets: map[string][]string{
+				"orders": {"orders"},
+			},
+		},
+		{
+			name:  "invalid rules",
+			rules: `{ "subscriptions": { "orders": [ { "collection": "" } ] } }`,
+			collections: map[string][]string{
+				"accounts": {"users"},
+				"orders":   {"orders"},
+			},
+			targets: map[string][]string{
+				"orders": {"orders"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			writeRules(t, filename, test.rules)
+			reloader.Reload(false)
+
+			if collections.dispatching != 0 {
+				t.Errorf("collections were subscribed while %d messages were dispatched", collections.dispatching)
+			}
+
+			if !reflect.DeepEqual(collections.collections, test.collections) {
+				t.Errorf("collections = %v, want %v", collections.collections, test.collections)
+			}
+
+			targets := subscriber.getRuleConfig().GetCollectionMap()
+			if !reflect.DeepEqual(targets, test.targets) {
+				t.Errorf("targets = %v, want %v", targets, test.targets)
+			}
+		})
+	}
+
+	close(stop)
+	wg.Wait()
+
+	// Events of collection removed are acknowledged without writing. Test writer
+	// never completes commands, so it is in another pipeline to not wait for them.
+	before := len(writer.getOperations())
+	acked = 0
+	msg := newDataMessage("accounts", 1, &acked)
+	msg.Payload.(*gravity_subscriber.DataEvent).PipelineID = 1
+	subscriber.eventHandler(msg)
+
+	if n := len(writer.getOperations()) - before; n != 0 {
+		t.Errorf("%d operations were written for collection removed", n)
+	}
+
+	if acked != 1 {
+		t.Errorf("acked = %d, want 1", acked)
+	}
+}
@@ -1,6 +1,7 @@
 package subscriber
 
 import (
+	"github.com/BrobridgeOrg/gravity-sdk/core/store"
 	gravity_state_store "github.com/BrobridgeOrg/gravity-sdk/subscriber/state_store"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -16,13 +17,47 @@ func (subscriber *Subscriber) InitStateStore() error {
 	// Initializing state store
 	options := gravity_state_store.NewOptions()
 	options.Core.StoreOptions.DatabasePath = storePath
-	stateStore := gravity_state_store.NewStateStore(options)
-	err := stateStore.Initialize()
+
+	// Keep underlying store to close it when shutting down
+	s, err := store.NewStore(options.Core)
+	if err != nil {
+		return err
+	}
+
+	stateStore := gravity_state_store.NewStateStoreWithStore(s, options)
+	err = stateStore.Initialize()
 	if err != nil {
-		return nil
+		return err
 	}
 
+	subscriber.store = s
 	subscriber.stateStore = stateStore
 
-	return nil
+	return subscriber.initTruncation()
+}
+
+func (subscriber *Subscriber) CloseStateStore() {
+
+	if subscriber.store == nil {
+		return
+	}
+
+	log.Info("Closing state store")
+
+	// Flush states of pipelines to disk
+	for _, pipelineID := range subscriber.stateStore.GetPipelines() {
+		pipelineState, err := subscriber.stateStore.GetPipelineState(pipelineID)
+		if err != nil {
+			continue
+		}
+
+		err = pipelineState.Flush()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"pipeline": pipelineID,
+			}).Errorf("Failed to flush state store: %v", err)
+		}
+	}
+
+	subscriber.store.GetEngine().Close()
 }
@@ -1,35 +1,57 @@
 package subscriber
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
+	broton "github.com/BrobridgeOrg/broton"
 	"github.com/BrobridgeOrg/gravity-sdk/core"
 	"github.com/BrobridgeOrg/gravity-sdk/core/keyring"
+	"github.com/BrobridgeOrg/gravity-sdk/core/store"
 	gravity_subscriber "github.com/BrobridgeOrg/gravity-sdk/subscriber"
 	gravity_state_store "github.com/BrobridgeOrg/gravity-sdk/subscriber/state_store"
 	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
 	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/app"
 	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/database"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
 	"github.com/jinzhu/copier"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 type Subscriber struct {
-	app               app.App
-	stateStore        *gravity_state_store.StateStore
-	subscriber        *gravity_subscriber.Subscriber
-	ruleConfig        *RuleConfig
-	completionCounter map[*gravity_subscriber.Message]int
+	app        app.App
+	client     *core.Client
+	store      *store.Store
+	stateStore *gravity_state_store.StateStore
+	subscriber *gravity_subscriber.Subscriber
+	ruleConfig atomic.Value
+	// collections is the subscriber of gravity SDK, it is replaced in tests
+	collections collectionSubscriber
+	// dispatching is held by handlers while messages are dispatched, and it is
+	// locked exclusively to change subscriptions. It only keeps our handlers
+	// away, see Run for readers of collections in gravity SDK.
+	dispatching sync.RWMutex
+	reloader    *RuleReloader
+	ackTracker  *AckTracker
+	truncation  *broton.Store
+	truncated   sync.Map
+	stopping    int32
+}
+
+// collectionSubscriber changes collections subscribed. Collections of gravity SDK
+// subscriber are not guarded, they must not be changed while they are read.
+type collectionSubscriber interface {
+	SubscribeToCollections(map[string][]string) error
 }
 
 func NewSubscriber(a app.App) *Subscriber {
 	return &Subscriber{
-		app:               a,
-		completionCounter: make(map[*gravity_subscriber.Message]int),
+		app:        a,
+		ackTracker: NewAckTracker(),
 	}
 }
 
@@ -38,53 +60,71 @@ func (subscriber *Subscriber) processData(msg *gravity_subscriber.Message) error
 	event := msg.Payload.(*gravity_subscriber.DataEvent)
 	record := event.Payload
 
+	metrics.RecordsReceived.WithLabelValues(record.Table, strconv.FormatUint(event.PipelineID, 10)).Inc()
+
 	// Getting tables for specific collection
-	tables, ok := subscriber.ruleConfig.Subscriptions[record.Table]
-	if !ok {
-		// skip
-		return nil
-	}
+	targets := subscriber.getRuleConfig().Subscriptions[record.Table]
 
 	//	log.Info(string(msg.Event.Data))
 
-	// Save record to each table
+	subscriber.dispatch(msg, record, targets)
+
+	return nil
+}
+
+// dispatch saves record to each target, message is acknowledged immediately if
+// there is nothing to write.
+func (subscriber *Subscriber) dispatch(msg *gravity_subscriber.Message, record *gravity_sdk_types_record.Record, targets []*rule.Target) {
+
+	subscriber.ackTracker.Begin(msg)
+
+	pipelineID, sequence := getPosition(msg)
+
+	// Checkpoint must not pass the event while its commands are being pushed
 	writer := subscriber.app.GetWriter()
-	for _, tableName := range tables {
+	writer.Begin(pipelineID, sequence)
+
+	for _, target := range targets {
 		var rs gravity_sdk_types_record.Record
 		copier.Copy(&rs, record)
-		rs.Table = tableName
+		rs.Table = target.Collection
+		target.Transform(&rs)
 
 		// TODO: using batch mechanism to improve performance
 		for {
-			err := writer.ProcessData(msg, &rs, tables)
+			enqueued, err := writer.ProcessData(msg, pipelineID, sequence, &rs, target)
 			if err == nil {
+				if enqueued {
+					subscriber.ackTracker.Add(msg)
+				}
+
 				break
 			}
 
 			log.Error(err)
+
+			// Writer was closed, leave message to be delivered again
+			if subscriber.isStopping() {
+				return
+			}
 		}
 	}
 
-	return nil
+	writer.Seal(pipelineID, sequence)
+	subscriber.ackTracker.Seal(msg)
 }
 
-func (subscriber *Subscriber) LoadConfigFile(filename string) (*RuleConfig, error) {
+// getPosition returns where message is in pipeline, snapshot has no sequence
+func getPosition(msg *gravity_subscriber.Message) (uint64, uint64) {
 
-	// Open and read config file
-	jsonFile, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+	switch event := msg.Payload.(type) {
+	case *gravity_subscriber.DataEvent:
+		return event.PipelineID, event.Sequence
+	case *gravity_subscriber.SnapshotEvent:
+		return event.PipelineID, 0
 	}
 
-	defer jsonFile.Close()
-
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-
-	// Parse config
-	var config RuleConfig
-	json.Unmarshal(byteValue, &config)
-
-	return &config, nil
+	return 0, 0
 }
 
 func (subscriber *Subscriber) Init() error {
@@ -96,12 +136,13 @@ func (subscriber *Subscriber) Init() error {
 		"ruleFile": ruleFile,
 	}).Info("Loading rules...")
 
-	ruleConfig, err := subscriber.LoadConfigFile(ruleFile)
+	ruleConfig, err := rule.LoadFile(ruleFile)
 	if err != nil {
 		return err
 	}
 
-	subscriber.ruleConfig = ruleConfig
+	subscriber.ruleConfig.Store(ruleConfig)
+	subscriber.reloader = NewRuleReloader(subscriber, ruleFile)
 
 	// Load state
 	err = subscriber.InitStateStore()
@@ -116,13 +157,11 @@ func (subscriber *Subscriber) Init() error {
 		ref := cmd.GetReference()
 		msg := ref.(*gravity_subscriber.Message)
 
-		tables := cmd.GetTables()
+		subscriber.ackTracker.Complete(msg)
+	})
 
-		subscriber.completionCounter[msg] += 1
-		if subscriber.completionCounter[msg] == len(tables) {
-			delete(subscriber.completionCounter, msg)
-			msg.Ack()
-		}
+	metrics.RegisterGauge("unacked_messages", "Number of messages waiting for being written", func() float64 {
+		return float64(subscriber.ackTracker.Count())
 	})
 
 	// Initializing gravity node information
@@ -150,13 +189,17 @@ func (subscriber *Subscriber) Init() error {
 	viper.SetDefault("subscriber.accessKey", "")
 	options.Key = keyring.NewKey(viper.GetString("subscriber.appID"), viper.GetString("subscriber.accessKey"))
 
-	subscriber.subscriber = gravity_subscriber.NewSubscriber(options)
+	// Keep client to check connection state
+	subscriber.client = core.NewClient()
 	opts := core.NewOptions()
-	err = subscriber.subscriber.Connect(host, opts)
+	err = subscriber.client.Connect(host, opts)
 	if err != nil {
 		return err
 	}
 
+	subscriber.subscriber = gravity_subscriber.NewSubscriberWithClient(subscriber.client, options)
+	subscriber.collections = subscriber.subscriber
+
 	// Setup data handler
 	subscriber.subscriber.SetEventHandler(subscriber.eventHandler)
 	subscriber.subscriber.SetSnapshotHandler(subscriber.snapshotHandler)
@@ -171,7 +214,7 @@ func (subscriber *Subscriber) Init() error {
 	}
 
 	// Subscribe to collections
-	err = subscriber.subscriber.SubscribeToCollections(subscriber.ruleConfig.Subscriptions)
+	err = subscriber.collections.SubscribeToCollections(ruleConfig.GetCollectionMap())
 	if err != nil {
 		return err
 	}
@@ -239,6 +282,15 @@ func (subscriber *Subscriber) initializePipelines() error {
 }
 
 func (subscriber *Subscriber) eventHandler(msg *gravity_subscriber.Message) {
+
+	// Leave it to be delivered again next time
+	if subscriber.isStopping() {
+		return
+	}
+
+	subscriber.dispatching.RLock()
+	defer subscriber.dispatching.RUnlock()
+
 	err := subscriber.processData(msg)
 	if err != nil {
 		log.Error(err)
@@ -248,13 +300,25 @@ func (subscriber *Subscriber) eventHandler(msg *gravity_subscriber.Message) {
 
 func (subscriber *Subscriber) snapshotHandler(msg *gravity_subscriber.Message) {
 
+	// Leave it to be delivered again next time
+	if subscriber.isStopping() {
+		return
+	}
+
+	subscriber.dispatching.RLock()
+	defer subscriber.dispatching.RUnlock()
+
 	event := msg.Payload.(*gravity_subscriber.SnapshotEvent)
 	snapshotRecord := event.Payload
 
+	metrics.RecordsReceived.WithLabelValues(event.Collection, strconv.FormatUint(event.PipelineID, 10)).Inc()
+
 	// Getting tables for specific collection
-	tables, ok := subscriber.ruleConfig.Subscriptions[event.Collection]
-	if !ok {
-		return
+	targets := subscriber.getRuleConfig().Subscriptions[event.Collection]
+
+	// Clear target collections before loading the first snapshot record
+	if len(targets) > 0 && viper.GetBool("initialLoad.truncate") {
+		subscriber.truncateOnce(event.Collection, targets)
 	}
 
 	// Prepare record for database writer
@@ -262,33 +326,51 @@ func (subscriber *Subscriber) snapshotHandler(msg *gravity_subscriber.Message) {
 	record.Method = gravity_sdk_types_record.Method_INSERT
 	record.Fields = snapshotRecord.Payload.Map.Fields
 
-	// Save record to each table
-	writer := subscriber.app.GetWriter()
-	for _, tableName := range tables {
-		var rs gravity_sdk_types_record.Record
-		copier.Copy(&rs, record)
-		rs.Table = tableName
-
-		// TODO: using batch mechanism to improve performance
-		for {
-			err := writer.ProcessData(msg, &rs, tables)
-			if err == nil {
-				break
-			}
+	subscriber.dispatch(msg, &record, targets)
+}
 
-			log.Error(err)
-		}
-	}
+// getRuleConfig returns rules in use, it could be replaced by reloading at any time
+// so that it should be loaded once for each message.
+func (subscriber *Subscriber) getRuleConfig() *rule.RuleConfig {
+	return subscriber.ruleConfig.Load().(*rule.RuleConfig)
 }
 
 func (subscriber *Subscriber) Run() error {
 
 	subscriber.subscriber.Start()
 
+	// Watch rules file for changes. Gravity SDK reads collections without lock
+	// when it prepares snapshots for initial load, which is done while adding
+	// pipelines in Init, so that rules must not be reloaded before.
+	go subscriber.reloader.Run()
+
 	return nil
 }
 
+func (subscriber *Subscriber) IsConnected() bool {
+
+	if subscriber.client == nil {
+		return false
+	}
+
+	conn := subscriber.client.GetConnection()
+
+	return conn != nil && conn.IsConnected()
+}
+
+func (subscriber *Subscriber) isStopping() bool {
+	return atomic.LoadInt32(&subscriber.stopping) == 1
+}
+
+// Stop stops receiving data from gravity
 func (subscriber *Subscriber) Stop() {
+	atomic.StoreInt32(&subscriber.stopping, 1)
+	subscriber.reloader.Stop()
 	subscriber.subscriber.Disconnect()
+}
 
+// Close releases state store so no more message can be acknowledged
+func (subscriber *Subscriber) Close() {
+	subscriber.ackTracker.Close()
+	subscriber.CloseStateStore()
 }
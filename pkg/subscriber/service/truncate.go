@@ -0,0 +1,93 @@
+package subscriber
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	log "github.com/sirupsen/logrus"
+)
+
+const truncationColumn = "truncated"
+
+// initTruncation opens store which remembers collections truncated before
+// initial load, so they are not truncated again if initial load is resumed
+// after restart.
+func (subscriber *Subscriber) initTruncation() error {
+
+	s, err := subscriber.store.GetEngine().GetStore("mongodb_transmitter")
+	if err != nil {
+		return err
+	}
+
+	err = s.RegisterColumns([]string{truncationColumn})
+	if err != nil {
+		return err
+	}
+
+	subscriber.truncation = s
+
+	return nil
+}
+
+// truncateOnce clears targets of collection before its first snapshot record
+// is written. Workers receiving records of the same collection are blocked
+// until truncation is done.
+func (subscriber *Subscriber) truncateOnce(collection string, targets []*rule.Target) {
+
+	v, _ := subscriber.truncated.LoadOrStore(collection, &sync.Once{})
+	v.(*sync.Once).Do(func() {
+
+		truncated, err := subscriber.truncation.GetInt64(truncationColumn, []byte(collection))
+		if err != nil {
+			// Keep data rather than truncating it twice
+			log.WithFields(log.Fields{
+				"collection": collection,
+			}).Errorf("Failed to load truncation state, skip truncating: %v", err)
+			return
+		}
+
+		if truncated == 1 {
+			log.WithFields(log.Fields{
+				"collection": collection,
+			}).Info("Targets were truncated before, skip truncating")
+			return
+		}
+
+		err = subscriber.truncateTargets(targets)
+		if err != nil {
+			return
+		}
+
+		err = subscriber.truncation.PutInt64(truncationColumn, []byte(collection), 1)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"collection": collection,
+			}).Errorf("Failed to save truncation state: %v", err)
+		}
+	})
+}
+
+func (subscriber *Subscriber) truncateTargets(targets []*rule.Target) error {
+
+	writer := subscriber.app.GetWriter()
+	for _, target := range targets {
+		for {
+			err := writer.Truncate(target)
+			if err == nil {
+				break
+			}
+
+			log.Error(err)
+
+			// Writer was closed
+			if subscriber.isStopping() {
+				return err
+			}
+
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	return nil
+}
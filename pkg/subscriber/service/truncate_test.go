@@ -0,0 +1,174 @@
+package subscriber
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gravity_subscriber "github.com/BrobridgeOrg/gravity-sdk/subscriber"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/database"
+	"github.com/BrobridgeOrg/gravity-transmitter-mongodb/pkg/rule"
+	"github.com/spf13/viper"
+)
+
+// testWriter records operations instead of writing them
+type testWriter struct {
+	mutex      sync.Mutex
+	operations []string
+	// dispatching is the number of messages between Begin and Seal
+	dispatching int32
+}
+
+func (writer *testWriter) Init() error {
+	return nil
+}
+
+func (writer *testWriter) Begin(uint64, uint64) {
+	atomic.AddInt32(&writer.dispatching, 1)
+}
+
+func (writer *testWriter) Seal(uint64, uint64) {
+	atomic.AddInt32(&writer.dispatching, -1)
+}
+
+func (writer *testWriter) ProcessData(reference interface{}, pipelineID uint64, sequence uint64, record *gravity_sdk_types_record.Record, target *rule.Target) (bool, error) {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.operations = append(writer.operations, "write "+target.Collection)
+
+	return true, nil
+}
+
+func (writer *testWriter) SetCompletionHandler(database.CompletionHandler) {
+}
+
+func (writer *testWriter) Truncate(target *rule.Target) error {
+
+	// Give other workers a chance to overtake truncation
+	time.Sleep(50 * time.Millisecond)
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.operations = append(writer.operations, "truncate "+target.Collection)
+
+	return nil
+}
+
+func (writer *testWriter) PrepareTargets([]*rule.Target) error {
+	return nil
+}
+
+func (writer *testWriter) getOperations() []string {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	return append([]string{}, writer.operations...)
+}
+
+type testApp struct {
+	writer *testWriter
+}
+
+func (a *testApp) GetWriter() database.Writer {
+	return a.writer
+}
+
+func newTestSubscriber(t *testing.T, stateStore string, ruleConfig *rule.RuleConfig) (*Subscriber, *testWriter) {
+
+	viper.Set("subscriber.stateStore", stateStore)
+
+	writer := &testWriter{}
+	subscriber := NewSubscriber(&testApp{writer: writer})
+	subscriber.ruleConfig.Store(ruleConfig)
+
+	err := subscriber.InitStateStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return subscriber, writer
+}
+
+func newSnapshotMessage(t *testing.T, collection string, id int64) *gravity_subscriber.Message {
+
+	payload, err := gravity_sdk_types_record.CreateValue(gravity_sdk_types_record.DataType_MAP, map[string]interface{}{
+		"id": id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &gravity_subscriber.Message{
+		Payload: &gravity_subscriber.SnapshotEvent{
+			Collection: collection,
+			Payload: &gravity_sdk_types_snapshot_record.SnapshotRecord{
+				Payload: payload,
+			},
+		},
+	}
+}
+
+func TestTruncateBeforeSnapshot(t *testing.T) {
+
+	viper.Set("initialLoad.truncate", true)
+	defer viper.Set("initialLoad.truncate", false)
+
+	ruleConfig := &rule.RuleConfig{
+		Subscriptions: rule.SubscriptionConfig{
+			"accounts": []*rule.Target{{Collection: "users"}},
+		},
+	}
+
+	stateStore := t.TempDir()
+
+	tests := []struct {
+		name      string
+		truncates int
+	}{
+		{"first initial load", 1},
+		{"resumed after restart", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			subscriber, writer := newTestSubscriber(t, stateStore, ruleConfig)
+			defer subscriber.CloseStateStore()
+
+			// Snapshot records are handled by several workers at the same time
+			var wg sync.WaitGroup
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func(id int64) {
+					defer wg.Done()
+					subscriber.snapshotHandler(newSnapshotMessage(t, "accounts", id))
+				}(int64(i))
+			}
+
+			wg.Wait()
+
+			operations := writer.getOperations()
+			if len(operations) != 16+test.truncates {
+				t.Fatalf("expected %d operations, got %v", 16+test.truncates, operations)
+			}
+
+			for i, op := range operations {
+				expected := "write users"
+				if i < test.truncates {
+					expected = "truncate users"
+				}
+
+				if op != expected {
+					t.Fatalf("expected %s at %d, got %v", expected, i, operations)
+				}
+			}
+		})
+	}
+}
@@ -4,4 +4,5 @@ type Subscriber interface {
 	Init() error
 	Run() error
 	Stop()
+	Close()
 }
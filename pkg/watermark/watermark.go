@@ -0,0 +1,56 @@
+package watermark
+
+// Watermark tracks sequences of a pipeline which are in progress. It is not
+// safe for concurrent use, callers have to guard it.
+type Watermark struct {
+	pending map[uint64]int
+	last    uint64
+}
+
+func NewWatermark() *Watermark {
+	return &Watermark{
+		pending: make(map[uint64]int),
+	}
+}
+
+// Hold marks sequence as in progress, it could be held more than once
+func (w *Watermark) Hold(sequence uint64) {
+
+	w.pending[sequence]++
+	if sequence > w.last {
+		w.last = sequence
+	}
+}
+
+// Release marks one of holds of sequence as done
+func (w *Watermark) Release(sequence uint64) {
+
+	count, ok := w.pending[sequence]
+	if !ok {
+		return
+	}
+
+	if count <= 1 {
+		delete(w.pending, sequence)
+		return
+	}
+
+	w.pending[sequence] = count - 1
+}
+
+// Get returns the last sequence which all sequences before were done
+func (w *Watermark) Get() uint64 {
+
+	if len(w.pending) == 0 {
+		return w.last
+	}
+
+	var min uint64
+	for seq := range w.pending {
+		if min == 0 || seq < min {
+			min = seq
+		}
+	}
+
+	return min - 1
+}
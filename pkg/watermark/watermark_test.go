@@ -0,0 +1,45 @@
+package watermark
+
+import (
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+
+	type step struct {
+		op       string
+		sequence uint64
+	}
+
+	tests := []struct {
+		name     string
+		steps    []step
+		expected uint64
+	}{
+		{"nothing", nil, 0},
+		{"all done", []step{{"hold", 1}, {"hold", 2}, {"release", 1}, {"release", 2}}, 2},
+		{"done out of order", []step{{"hold", 10}, {"hold", 11}, {"release", 11}}, 9},
+		{"gap filled", []step{{"hold", 10}, {"hold", 11}, {"release", 11}, {"release", 10}}, 11},
+		{"held twice", []step{{"hold", 5}, {"hold", 5}, {"release", 5}}, 4},
+		{"released too many times", []step{{"hold", 5}, {"release", 5}, {"release", 5}, {"hold", 6}}, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			w := NewWatermark()
+			for _, s := range test.steps {
+				switch s.op {
+				case "hold":
+					w.Hold(s.sequence)
+				case "release":
+					w.Release(s.sequence)
+				}
+			}
+
+			if w.Get() != test.expected {
+				t.Fatalf("expected watermark %d, got %d", test.expected, w.Get())
+			}
+		})
+	}
+}